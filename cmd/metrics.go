@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"PoS-Security-Simulator/pos"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// metricsFlags select where per-slot metrics are written
+type metricsFlags struct {
+	path   string
+	format string
+}
+
+func addMetricsFlags(cmd *cobra.Command) *metricsFlags {
+	f := &metricsFlags{}
+	cmd.Flags().StringVar(&f.path, "metrics", "", "write the metrics of every time slot to this file")
+	cmd.Flags().StringVar(&f.format, "metrics-format", "", "csv or jsonl, guessed from the --metrics extension by default")
+	return f
+}
+
+// open creates the metrics file, or returns a nil writer if none was asked
+// for. The returned close function must be called once the run is over.
+func (f *metricsFlags) open() (pos.MetricsWriter, func() error, error) {
+	if f.path == "" {
+		return nil, func() error { return nil }, nil
+	}
+	format := f.format
+	if format == "" {
+		format = pos.MetricsFormat(f.path)
+	}
+
+	file, err := os.Create(f.path)
+	if err != nil {
+		return nil, nil, err
+	}
+	writer, err := pos.NewMetricsWriter(file, format)
+	if err != nil {
+		file.Close()
+		os.Remove(f.path)
+		return nil, nil, err
+	}
+	return writer, file.Close, nil
+}
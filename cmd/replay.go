@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"PoS-Security-Simulator/pos"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/spf13/cobra"
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <record.json>",
+	Short: "Run a recorded scenario again and check it reaches the same evaluation",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		record, err := pos.ReadRunRecord(args[0])
+		if err != nil {
+			return err
+		}
+		//a served run depends on when nodes joined and on the wall clock
+		if record.Scenario.RunType != "headless" || record.Scenario.Clock != "virtual" {
+			return errors.New("only headless runs on the virtual clock can be replayed, not served or real-time ones")
+		}
+
+		results, err := pos.Simulate(cmd.Context(), record.Scenario, nil)
+		if err != nil {
+			return err
+		}
+		if results.StopReason == "cancelled" {
+			return cmd.Context().Err()
+		}
+		if !reflect.DeepEqual(results.Evaluation, record.Evaluation) {
+			fmt.Printf("recorded: %+v\n", record.Evaluation)
+			fmt.Printf("replayed: %+v\n", results.Evaluation)
+			return errors.New("replay diverged from the recorded run")
+		}
+		fmt.Printf("replay of seed %d matches the recorded run\n", record.Scenario.Seed)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"PoS-Security-Simulator/pos"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// replay records the run of s and replays the record after modify changed it
+func replay(t *testing.T, s pos.Scenario, modify func(record *pos.RunRecord)) error {
+	t.Helper()
+	results, err := pos.Simulate(context.Background(), s, nil)
+	if err != nil {
+		t.Fatalf("Simulate: %v", err)
+	}
+	record := pos.RunRecord{Scenario: results.Scenario, Evaluation: results.Evaluation}
+	modify(&record)
+	path := filepath.Join(t.TempDir(), "run.json")
+	if err := pos.WriteRunRecord(path, record); err != nil {
+		t.Fatalf("WriteRunRecord: %v", err)
+	}
+	rootCmd.SetArgs([]string{"replay", path})
+	return rootCmd.ExecuteContext(context.Background())
+}
+
+func TestReplay(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(record *pos.RunRecord)
+		// Substring of the error, empty if the replay matches
+		err string
+	}{
+		{"recorded run", func(record *pos.RunRecord) {}, ""},
+		{"diverged", func(record *pos.RunRecord) { record.Evaluation.TotalBlocks++ }, "replay diverged"},
+		{"served run", func(record *pos.RunRecord) { record.Scenario.RunType = "auto" }, "only headless runs"},
+		{"real-time run", func(record *pos.RunRecord) { record.Scenario.Clock = "real" }, "only headless runs"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := pos.DefaultScenario()
+			s.RunType = "headless"
+			s.Seed = 5
+			s.NumSlots = 30
+			err := replay(t, s, test.modify)
+			switch {
+			case test.err == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case test.err != "" && err == nil:
+				t.Errorf("expected an error containing %q", test.err)
+			case test.err != "" && !strings.Contains(err.Error(), test.err):
+				t.Errorf("error %q does not contain %q", err, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"PoS-Security-Simulator/pos"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report <record.json>...",
+	Short: "Summarise the evaluations of recorded runs",
+	Args:  cobra.MinimumNArgs(1),
+}
+
+func init() {
+	security := reportCmd.Flags().Bool("security", false, "print the security report of every run as Markdown instead of the table")
+	reportCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *security {
+			for _, path := range args {
+				record, err := pos.ReadRunRecord(path)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("<!-- %s -->\n%s\n", path, record.Evaluation.Security.Markdown())
+			}
+			return nil
+		}
+
+		table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "RUN\tTYPE\tATTACK\tMALICIOUS\tSEED\tBLOCKS\tMALICIOUS BLOCKS\tTRANSACTIONS")
+		for _, path := range args {
+			record, err := pos.ReadRunRecord(path)
+			if err != nil {
+				return err
+			}
+			s := record.Scenario
+			e := record.Evaluation
+			fmt.Fprintf(table, "%s\t%s\t%s\t%d/%d\t%d\t%d\t%d\t%d\n", path, s.BlockchainType, s.Attack, s.NumMal, s.NumValidators, s.Seed, e.TotalBlocks, e.MaliciousBlocks, e.TransactionsValidated)
+		}
+		return table.Flush()
+	}
+	rootCmd.AddCommand(reportCmd)
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"PoS-Security-Simulator/pos"
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var rootCmd = &cobra.Command{
+	Use:          "PoS-Security-Simulator",
+	Short:        "Simulate attacks on proof of stake blockchains",
+	SilenceUsage: true,
+}
+
+// Execute runs the command named on the command line. Ctrl-C or SIGTERM
+// stops a running simulation gracefully, with its final results.
+func Execute() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// scenarioFlags are the flags shared by every command that runs a scenario.
+// Flags left unset fall back to the scenario file, then to the defaults.
+type scenarioFlags struct {
+	flags     *pflag.FlagSet
+	path      string
+	overrides map[string]func(*pos.Scenario)
+}
+
+func addScenarioFlags(cmd *cobra.Command) *scenarioFlags {
+	f := &scenarioFlags{
+		flags:     cmd.Flags(),
+		overrides: make(map[string]func(*pos.Scenario)),
+	}
+	f.flags.StringVar(&f.path, "scenario", "", "YAML or JSON scenario file")
+	f.intVar("validators", func(s *pos.Scenario) *int { return &s.NumValidators }, "number of validators")
+	f.intVar("users", func(s *pos.Scenario) *int { return &s.NumUsers }, "number of users making transactions")
+	f.intVar("malicious", func(s *pos.Scenario) *int { return &s.NumMal }, "number of malicious validators")
+	f.intVar("committee-size", func(s *pos.Scenario) *int { return &s.CommitteeSize }, "validators voting on each block")
+	f.intVar("delegate-size", func(s *pos.Scenario) *int { return &s.DelegateSize }, "delegates elected in reputation, dpos and npos mode")
+	f.stringVar("blockchain-type", func(s *pos.Scenario) *string { return &s.BlockchainType }, "pos, slashing, reputation, tendermint, ouroboros, hotstuff, dpos or npos")
+	f.stringVar("attack", func(s *pos.Scenario) *string { return &s.Attack }, "network_partition, balance, targeted_dos, vote_buying, long_range, nothing_at_stake, selfish_proposing, censorship, double_spend or none, or attacks joined by + such as network_partition+balance")
+	f.stringVar("finality", func(s *pos.Scenario) *string { return &s.Finality }, "none, or casper_ffg to finalize checkpoints on top of longest chain consensus")
+	f.stringVar("sortition", func(s *pos.Scenario) *string { return &s.Sortition }, "central, or vrf for validators to select themselves for the committee of pos and slashing")
+	f.intVar("dos-budget", func(s *pos.Scenario) *int { return &s.DosBudget }, "validators a targeted_dos attacker knocks offline every slot")
+	f.stringVar("fork-choice", func(s *pos.Scenario) *string { return &s.ForkChoice }, "longest_chain or lmd_ghost")
+	f.intVar("epoch-length", func(s *pos.Scenario) *int { return &s.EpochLength }, "blocks between two casper_ffg checkpoints, and time slots of an ouroboros epoch")
+	f.float64Var("active-slot-coefficient", func(s *pos.Scenario) *float64 { return &s.ActiveSlotCoefficient }, "chance that an ouroboros slot has a leader, were one validator to hold all the stake")
+	f.float64Var("vote-rate", func(s *pos.Scenario) *float64 { return &s.VoteRate }, "chance that a transaction of a user is a vote under dpos or npos")
+	f.intVar("max-nominations", func(s *pos.Scenario) *int { return &s.MaxNominations }, "validators a user nominates at most under npos")
+	f.float64Var("bribe", func(s *pos.Scenario) *float64 { return &s.Bribe }, "share of the tokens a voter locks that a vote_buying cartel pays for its vote")
+	f.intVar("long-range-fork-height", func(s *pos.Scenario) *int { return &s.LongRangeForkHeight }, "height of the certified chain a long_range attacker rewrites history from")
+	f.intVar("join-interval", func(s *pos.Scenario) *int { return &s.JoinInterval }, "time slots between two honest validators joining after the start, 0 for none")
+	f.intVar("withhold-slots", func(s *pos.Scenario) *int { return &s.WithholdSlots }, "time slots a selfish_proposing attacker withholds its private chain at least before releasing it")
+	f.intVar("censored-users", func(s *pos.Scenario) *int { return &s.CensoredUsers }, "users a censorship attacker targets, the first ones to join")
+	f.boolVar("slash-equivocation", func(s *pos.Scenario) *bool { return &s.SlashEquivocation }, "slash validators signing two blocks or voting for both blocks of a time slot")
+	f.int64Var("seed", func(s *pos.Scenario) *int64 { return &s.Seed }, "seed for all randomness, 0 picks one from the current time")
+	f.intVar("slots", func(s *pos.Scenario) *int { return &s.NumSlots }, "stop after this many time slots, 0 for no limit")
+	f.intVar("max-blocks", func(s *pos.Scenario) *int { return &s.MaxBlocks }, "stop once the certified chain holds this many blocks, 0 for no limit")
+	f.durationVar("duration", func(s *pos.Scenario) *pos.Duration { return &s.Duration }, "stop after this much time on the run's clock, 0 for no limit")
+	f.stringVar("clock", func(s *pos.Scenario) *string { return &s.Clock }, "virtual or real")
+	f.durationVar("slot-duration", func(s *pos.Scenario) *pos.Duration { return &s.SlotDuration }, "time between two time slots")
+	f.durationVar("transaction-interval", func(s *pos.Scenario) *pos.Duration { return &s.TransactionInterval }, "time between two transactions of a user")
+	f.intVar("consensus-interval", func(s *pos.Scenario) *int { return &s.ConsensusInterval }, "time slots between longest chain consensus rounds")
+	f.float64Var("slash-ratio", func(s *pos.Scenario) *float64 { return &s.SlashRatio }, "fraction of stake a slashed validator keeps")
+	f.float64Var("reputation-slash-ratio", func(s *pos.Scenario) *float64 { return &s.ReputationSlashRatio }, "fraction of reputation a punished proposer keeps")
+	f.float64Var("vote-reputation-slash-ratio", func(s *pos.Scenario) *float64 { return &s.VoteReputationSlashRatio }, "fraction of reputation a delegate voting against the majority keeps")
+	return f
+}
+
+func (f *scenarioFlags) intVar(name string, field func(*pos.Scenario) *int, usage string) {
+	defaults := pos.DefaultScenario()
+	value := f.flags.Int(name, *field(&defaults), usage)
+	f.overrides[name] = func(s *pos.Scenario) { *field(s) = *value }
+}
+
+func (f *scenarioFlags) int64Var(name string, field func(*pos.Scenario) *int64, usage string) {
+	defaults := pos.DefaultScenario()
+	value := f.flags.Int64(name, *field(&defaults), usage)
+	f.overrides[name] = func(s *pos.Scenario) { *field(s) = *value }
+}
+
+func (f *scenarioFlags) float64Var(name string, field func(*pos.Scenario) *float64, usage string) {
+	defaults := pos.DefaultScenario()
+	value := f.flags.Float64(name, *field(&defaults), usage)
+	f.overrides[name] = func(s *pos.Scenario) { *field(s) = *value }
+}
+
+func (f *scenarioFlags) stringVar(name string, field func(*pos.Scenario) *string, usage string) {
+	defaults := pos.DefaultScenario()
+	value := f.flags.String(name, *field(&defaults), usage)
+	f.overrides[name] = func(s *pos.Scenario) { *field(s) = *value }
+}
+
+func (f *scenarioFlags) boolVar(name string, field func(*pos.Scenario) *bool, usage string) {
+	defaults := pos.DefaultScenario()
+	value := f.flags.Bool(name, *field(&defaults), usage)
+	f.overrides[name] = func(s *pos.Scenario) { *field(s) = *value }
+}
+
+func (f *scenarioFlags) durationVar(name string, field func(*pos.Scenario) *pos.Duration, usage string) {
+	defaults := pos.DefaultScenario()
+	value := f.flags.Duration(name, time.Duration(*field(&defaults)), usage)
+	f.overrides[name] = func(s *pos.Scenario) { *field(s) = pos.Duration(*value) }
+}
+
+// scenario loads the scenario file, applies the flags that were set and picks
+// a seed if none was given, so the returned scenario can be replayed
+func (f *scenarioFlags) scenario() (pos.Scenario, error) {
+	scenario := pos.DefaultScenario()
+	if f.path != "" {
+		var err error
+		scenario, err = pos.LoadScenario(f.path)
+		if err != nil {
+			return scenario, err
+		}
+	}
+	f.flags.Visit(func(flag *pflag.Flag) {
+		if override, ok := f.overrides[flag.Name]; ok {
+			override(&scenario)
+		}
+	})
+	if scenario.Seed == 0 {
+		scenario.Seed = time.Now().UnixNano()
+	}
+	return scenario, scenario.Validate()
+}
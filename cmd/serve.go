@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"PoS-Security-Simulator/pos"
+	"errors"
+
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the simulation on the PORT from .env so nodes can join with netcat",
+	Args:  cobra.NoArgs,
+}
+
+func init() {
+	flags := addScenarioFlags(serveCmd)
+	metrics := addMetricsFlags(serveCmd)
+	flags.stringVar("run-type", func(s *pos.Scenario) *string { return &s.RunType }, "auto adds in-process validators and users, manual waits for netcat")
+	out := serveCmd.Flags().String("out", "", "write the scenario and its evaluation to this JSON file once the server stops")
+	serveCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		scenario, err := flags.scenario()
+		if err != nil {
+			return err
+		}
+		if scenario.RunType == "headless" {
+			return errors.New("serve needs run-type auto or manual, use simulate for headless runs")
+		}
+		//a server runs until interrupted unless it is given a number of slots
+		if !cmd.Flags().Changed("slots") && flags.path == "" {
+			scenario.NumSlots = 0
+		}
+
+		metricsWriter, closeMetrics, err := metrics.open()
+		if err != nil {
+			return err
+		}
+		results, err := pos.Run(cmd.Context(), scenario, metricsWriter)
+		closeErr := closeMetrics()
+		if err != nil {
+			return err
+		}
+		if closeErr != nil {
+			return closeErr
+		}
+		if *out == "" {
+			return nil
+		}
+		return pos.WriteRunRecord(*out, pos.RunRecord{Scenario: results.Scenario, Evaluation: results.Evaluation})
+	}
+	rootCmd.AddCommand(serveCmd)
+}
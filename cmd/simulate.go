@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"PoS-Security-Simulator/pos"
+
+	"github.com/spf13/cobra"
+)
+
+var simulateCmd = &cobra.Command{
+	Use:   "simulate",
+	Short: "Run a headless simulation without opening any TCP connections",
+	Args:  cobra.NoArgs,
+}
+
+func init() {
+	flags := addScenarioFlags(simulateCmd)
+	metrics := addMetricsFlags(simulateCmd)
+	out := simulateCmd.Flags().String("out", "", "write the scenario and its evaluation to this JSON file for replay and report")
+	securityReport := simulateCmd.Flags().String("security-report", "", "write the outcome of the attack to this file, as JSON if it ends in .json and as Markdown otherwise")
+	simulateCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		scenario, err := flags.scenario()
+		if err != nil {
+			return err
+		}
+		scenario.RunType = "headless"
+
+		metricsWriter, closeMetrics, err := metrics.open()
+		if err != nil {
+			return err
+		}
+		results, err := pos.Simulate(cmd.Context(), scenario, metricsWriter)
+		closeErr := closeMetrics()
+		if err != nil {
+			return err
+		}
+		if closeErr != nil {
+			return closeErr
+		}
+		if *securityReport != "" {
+			err = pos.WriteSecurityReport(*securityReport, results.Evaluation.Security)
+			if err != nil {
+				return err
+			}
+		}
+		if *out == "" {
+			return nil
+		}
+		return pos.WriteRunRecord(*out, pos.RunRecord{Scenario: results.Scenario, Evaluation: results.Evaluation})
+	}
+	rootCmd.AddCommand(simulateCmd)
+}
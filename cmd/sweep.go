@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"PoS-Security-Simulator/pos"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var sweepCmd = &cobra.Command{
+	Use:   "sweep",
+	Short: "Run seeded replicas of every combination of parameter values in parallel",
+	Long: `Run seeded replicas of every combination of parameter values in parallel and
+report the mean of each evaluation metric with its 95% confidence interval.
+
+Integer values are lists and ranges such as "20,50,70" or "2:10:2".`,
+	Args: cobra.NoArgs,
+}
+
+func init() {
+	flags := addScenarioFlags(sweepCmd)
+	replicas := sweepCmd.Flags().Int("replicas", 10, "seeded runs of every combination, replica i uses seed+i")
+	workers := sweepCmd.Flags().Int("workers", runtime.NumCPU(), "runs executing at the same time")
+	malValues := sweepCmd.Flags().StringSlice("malicious-values", nil, "numbers of malicious validators to sweep")
+	committeeSizes := sweepCmd.Flags().StringSlice("committee-sizes", nil, "committee sizes to sweep")
+	delegateSizes := sweepCmd.Flags().StringSlice("delegate-sizes", nil, "delegate sizes to sweep")
+	blockchainTypes := sweepCmd.Flags().StringSlice("blockchain-types", nil, "blockchain types to sweep")
+	attacks := sweepCmd.Flags().StringSlice("attacks", nil, "attacks to sweep")
+	out := sweepCmd.Flags().String("out", "", "write every combination with its runs and summaries to this JSON file")
+	outDir := sweepCmd.Flags().String("out-dir", "", "write a record of every run to this directory")
+	sweepCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		scenario, err := flags.scenario()
+		if err != nil {
+			return err
+		}
+		scenario.RunType = "headless"
+
+		spec := pos.SweepSpec{
+			Base:           scenario,
+			BlockchainType: *blockchainTypes,
+			Attack:         *attacks,
+			Replicas:       *replicas,
+		}
+		spec.NumMal, err = parseIntValues(*malValues)
+		if err != nil {
+			return fmt.Errorf("malicious-values: %w", err)
+		}
+		spec.CommitteeSize, err = parseIntValues(*committeeSizes)
+		if err != nil {
+			return fmt.Errorf("committee-sizes: %w", err)
+		}
+		spec.DelegateSize, err = parseIntValues(*delegateSizes)
+		if err != nil {
+			return fmt.Errorf("delegate-sizes: %w", err)
+		}
+
+		if *outDir != "" {
+			err = os.MkdirAll(*outDir, 0755)
+			if err != nil {
+				return err
+			}
+		}
+		cells, err := pos.Sweep(spec, *workers, func(s pos.Scenario) (pos.Evaluation, error) {
+			sim, err := pos.NewSimulation(s, nil, io.Discard)
+			if err != nil {
+				return pos.Evaluation{}, err
+			}
+			results, err := sim.Simulate(cmd.Context())
+			if err != nil {
+				return pos.Evaluation{}, err
+			}
+			if results.StopReason == "cancelled" {
+				return pos.Evaluation{}, cmd.Context().Err()
+			}
+			evaluation := results.Evaluation
+			if *outDir == "" {
+				return evaluation, nil
+			}
+			recordPath := filepath.Join(*outDir, fmt.Sprintf("%s-%s-mal%d-com%d-del%d-seed%d.json", s.BlockchainType, s.Attack, s.NumMal, s.CommitteeSize, s.DelegateSize, s.Seed))
+			return evaluation, pos.WriteRunRecord(recordPath, pos.RunRecord{Scenario: s, Evaluation: evaluation})
+		})
+		if err != nil {
+			return err
+		}
+
+		table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "TYPE\tATTACK\tMALICIOUS\tCOMMITTEE\tDELEGATES\tBLOCKS\tMALICIOUS BLOCKS\tTRANSACTIONS")
+		for _, cell := range cells {
+			s := cell.Scenario
+			fmt.Fprintf(table, "%s\t%s\t%d\t%d\t%d\t%s\t%s\t%s\n", s.BlockchainType, s.Attack, s.NumMal, s.CommitteeSize, s.DelegateSize, cell.TotalBlocks, cell.MaliciousBlocks, cell.TransactionsValidated)
+		}
+		err = table.Flush()
+		if err != nil || *out == "" {
+			return err
+		}
+		data, err := json.MarshalIndent(cells, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(*out, append(data, '\n'), 0644)
+	}
+	rootCmd.AddCommand(sweepCmd)
+}
+
+// parseIntValues expands values such as "20", "2:10" or "2:10:2", where
+// ranges include both ends
+func parseIntValues(values []string) ([]int, error) {
+	ints := make([]int, 0, len(values))
+	for _, value := range values {
+		parts := strings.Split(value, ":")
+		if len(parts) > 3 {
+			return nil, fmt.Errorf("%q is not a number or a start:end[:step] range", value)
+		}
+		bounds := make([]int, len(parts))
+		for i, part := range parts {
+			n, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				return nil, fmt.Errorf("%q is not a number or a start:end[:step] range", value)
+			}
+			bounds[i] = n
+		}
+		if len(bounds) == 1 {
+			ints = append(ints, bounds[0])
+			continue
+		}
+		step := 1
+		if len(bounds) == 3 {
+			step = bounds[2]
+		}
+		if step < 1 || bounds[1] < bounds[0] {
+			return nil, fmt.Errorf("%q must count up with a positive step", value)
+		}
+		for n := bounds[0]; n <= bounds[1]; n += step {
+			ints = append(ints, n)
+		}
+	}
+	return ints, nil
+}
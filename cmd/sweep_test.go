@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseIntValues(t *testing.T) {
+	tests := []struct {
+		values  []string
+		want    []int
+		wantErr bool
+	}{
+		{values: []string{"20"}, want: []int{20}},
+		{values: []string{"2:4"}, want: []int{2, 3, 4}},
+		{values: []string{"2:10:4"}, want: []int{2, 6, 10}},
+		{values: []string{"1", " 3 : 5 "}, want: []int{1, 3, 4, 5}},
+		{values: []string{"3:3"}, want: []int{3}},
+		{values: []string{"a"}, wantErr: true},
+		{values: []string{"1:2:3:4"}, wantErr: true},
+		{values: []string{"5:1"}, wantErr: true},
+		{values: []string{"1:5:0"}, wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := parseIntValues(test.values)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("parseIntValues(%q) = %v, want an error", test.values, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseIntValues(%q): %v", test.values, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseIntValues(%q) = %v, want %v", test.values, got, test.want)
+		}
+	}
+}
@@ -5,12 +5,10 @@ go 1.20
 require (
 	github.com/davecgh/go-spew v1.1.1
 	github.com/joho/godotenv v1.5.1
+	golang.org/x/exp v0.0.0-20230321023759-10a507213a29
 )
 
-require (
-	golang.org/x/exp v0.0.0-20230321023759-10a507213a29 // indirect
-	golang.org/x/tools v0.2.0 // indirect
-)
+require golang.org/x/tools v0.2.0 // indirect
 
 require (
 	github.com/cilium/ebpf v0.10.0 // indirect
@@ -30,12 +28,12 @@ require (
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
 	github.com/shurcooL/sanitized_anchor_name v1.0.0 // indirect
 	github.com/sirupsen/logrus v1.9.0 // indirect
-	github.com/spf13/cobra v1.6.1 // indirect
-	github.com/spf13/pflag v1.0.5 // indirect
+	github.com/spf13/cobra v1.6.1
+	github.com/spf13/pflag v1.0.5
 	go.starlark.net v0.0.0-20230302034142-4b1e35fe2254 // indirect
 	golang.org/x/arch v0.3.0 // indirect
 	golang.org/x/sys v0.6.0 // indirect
 	gonum.org/v1/gonum v0.12.0
 	gopkg.in/yaml.v2 v2.4.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
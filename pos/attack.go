@@ -0,0 +1,436 @@
+package pos
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// Attack is a strategy malicious validators play against the network. Its
+// hooks are called at fixed points of every time slot and may change what
+// happens next. Attacks combined in one scenario are called in the order
+// they are listed.
+type Attack interface {
+	// Setup prepares the network before any validator joins
+	Setup(sim *Simulation)
+	// OnValidatorJoin is called for every validator joining the network,
+	// before it takes part in any slot
+	OnValidatorJoin(sim *Simulation, validator *Validator)
+	// OnProposerSelected is called once the committee and proposer of slot
+	// are chosen
+	OnProposerSelected(sim *Simulation, slot *Slot)
+	// OnTransactionSelected returns whether proposer puts transaction in the
+	// block it builds, leaving room for the next one of its mempool if not
+	OnTransactionSelected(sim *Simulation, proposer *Validator, transaction Transaction) bool
+	// OnBlockGenerated is called once the proposer built the block of slot,
+	// and may add conflicting proposals. An error skips the rest of the slot.
+	OnBlockGenerated(sim *Simulation, slot *Slot) error
+	// OnVote returns how validator votes on block, given whether it found
+	// the block valid
+	OnVote(sim *Simulation, validator *Validator, block Block, valid bool) bool
+	// OnVotesCounted is called once the committee voted, and may change
+	// which proposals are accepted
+	OnVotesCounted(sim *Simulation, slot *Slot)
+	// OnBroadcast is called before accepted proposals are delivered, and may
+	// change which validators receive them
+	OnBroadcast(sim *Simulation, slot *Slot)
+	// OnBlockAccepted is called once an accepted proposal is delivered and its
+	// transactions settled
+	OnBlockAccepted(sim *Simulation, proposal *Proposal)
+	// OnAttest returns the block validator attests to under lmd_ghost, given
+	// the head of its chain, or an empty block to skip the attestation
+	OnAttest(sim *Simulation, validator *Validator, head Block) Block
+	// OnConsensus returns the validator whose chain wins a longest chain
+	// consensus round, given the one the protocol chose, or nil to delay
+	// consensus
+	OnConsensus(sim *Simulation, chosen *Validator) *Validator
+	// OnDelegateVote returns the validators user votes for under dpos or
+	// nominates under npos, given the ones it picked and the tokens it locks
+	OnDelegateVote(sim *Simulation, user *User, candidates []*Validator, amount float64) []*Validator
+}
+
+// Slot is the time slot in progress, as seen by the hooks of every attack
+type Slot struct {
+	Proposer  *Validator
+	Committee []*Validator
+	// Blocks proposed to the committee, more than one if the proposer
+	// equivocates
+	Proposals []*Proposal
+	Votes     Votes
+}
+
+// Proposal is a block proposed in a time slot and what became of it
+type Proposal struct {
+	Block    Block
+	Proposer *Validator
+	Accepted bool
+	// Validators the block is delivered to once accepted. Unless Forced, a
+	// validator only appends the block if it extends its own chain.
+	Recipients []*Validator
+	Forced     bool
+}
+
+// newAttack returns the attacks named in attack, joined by "+"
+func newAttack(attack string) (Attack, error) {
+	set := attackSet{}
+	for _, name := range strings.Split(attack, "+") {
+		switch name {
+		case "none":
+		case "network_partition":
+			set = append(set, &networkPartition{})
+		case "balance":
+			set = append(set, &balanceAttack{})
+		case "targeted_dos":
+			set = append(set, &targetedDoS{})
+		case "vote_buying":
+			set = append(set, &voteBuying{})
+		case "long_range":
+			set = append(set, &longRange{})
+		case "nothing_at_stake":
+			set = append(set, &nothingAtStake{})
+		case "selfish_proposing":
+			set = append(set, &selfishProposing{})
+		case "censorship":
+			set = append(set, &censorship{})
+		case "double_spend":
+			set = append(set, &doubleSpend{})
+		default:
+			return nil, fmt.Errorf("unknown attack %q", name)
+		}
+	}
+	return set, nil
+}
+
+// attackSet plays several attacks at once, calling their hooks in order
+type attackSet []Attack
+
+func (set attackSet) Setup(sim *Simulation) {
+	for _, attack := range set {
+		attack.Setup(sim)
+	}
+}
+
+func (set attackSet) OnValidatorJoin(sim *Simulation, validator *Validator) {
+	for _, attack := range set {
+		attack.OnValidatorJoin(sim, validator)
+	}
+}
+
+func (set attackSet) OnProposerSelected(sim *Simulation, slot *Slot) {
+	for _, attack := range set {
+		attack.OnProposerSelected(sim, slot)
+	}
+}
+
+func (set attackSet) OnTransactionSelected(sim *Simulation, proposer *Validator, transaction Transaction) bool {
+	for _, attack := range set {
+		if !attack.OnTransactionSelected(sim, proposer, transaction) {
+			return false
+		}
+	}
+	return true
+}
+
+func (set attackSet) OnBlockGenerated(sim *Simulation, slot *Slot) error {
+	for _, attack := range set {
+		err := attack.OnBlockGenerated(sim, slot)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (set attackSet) OnVote(sim *Simulation, validator *Validator, block Block, valid bool) bool {
+	for _, attack := range set {
+		valid = attack.OnVote(sim, validator, block, valid)
+	}
+	return valid
+}
+
+func (set attackSet) OnVotesCounted(sim *Simulation, slot *Slot) {
+	for _, attack := range set {
+		attack.OnVotesCounted(sim, slot)
+	}
+}
+
+func (set attackSet) OnBroadcast(sim *Simulation, slot *Slot) {
+	for _, attack := range set {
+		attack.OnBroadcast(sim, slot)
+	}
+}
+
+func (set attackSet) OnBlockAccepted(sim *Simulation, proposal *Proposal) {
+	for _, attack := range set {
+		attack.OnBlockAccepted(sim, proposal)
+	}
+}
+
+func (set attackSet) OnAttest(sim *Simulation, validator *Validator, head Block) Block {
+	for _, attack := range set {
+		head = attack.OnAttest(sim, validator, head)
+	}
+	return head
+}
+
+func (set attackSet) OnConsensus(sim *Simulation, chosen *Validator) *Validator {
+	for _, attack := range set {
+		if chosen == nil {
+			return nil
+		}
+		chosen = attack.OnConsensus(sim, chosen)
+	}
+	return chosen
+}
+
+func (set attackSet) OnDelegateVote(sim *Simulation, user *User, candidates []*Validator, amount float64) []*Validator {
+	for _, attack := range set {
+		candidates = attack.OnDelegateVote(sim, user, candidates, amount)
+	}
+	return candidates
+}
+
+// baseAttack leaves every hook without effect, for attacks to embed and
+// override the hooks they need
+type baseAttack struct{}
+
+func (baseAttack) Setup(sim *Simulation) {}
+
+func (baseAttack) OnValidatorJoin(sim *Simulation, validator *Validator) {}
+
+func (baseAttack) OnProposerSelected(sim *Simulation, slot *Slot) {}
+
+func (baseAttack) OnTransactionSelected(sim *Simulation, proposer *Validator, transaction Transaction) bool {
+	return true
+}
+
+func (baseAttack) OnBlockGenerated(sim *Simulation, slot *Slot) error { return nil }
+
+func (baseAttack) OnVote(sim *Simulation, validator *Validator, block Block, valid bool) bool {
+	return valid
+}
+
+func (baseAttack) OnVotesCounted(sim *Simulation, slot *Slot) {}
+
+func (baseAttack) OnBroadcast(sim *Simulation, slot *Slot) {}
+
+func (baseAttack) OnBlockAccepted(sim *Simulation, proposal *Proposal) {}
+
+func (baseAttack) OnAttest(sim *Simulation, validator *Validator, head Block) Block { return head }
+
+func (baseAttack) OnConsensus(sim *Simulation, chosen *Validator) *Validator { return chosen }
+
+func (baseAttack) OnDelegateVote(sim *Simulation, user *User, candidates []*Validator, amount float64) []*Validator {
+	return candidates
+}
+
+// networkPartition splits validators into two groups. A malicious proposer
+// sends each group a different block, and once both are accepted the chain
+// is forked: every block only reaches its proposer's group until longest
+// chain consensus settles the fork.
+type networkPartition struct {
+	baseAttack
+	groups [2][]*Validator
+	joined int
+}
+
+func (p *networkPartition) OnValidatorJoin(sim *Simulation, validator *Validator) {
+	p.groups[p.joined%2] = append(p.groups[p.joined%2], validator)
+	p.joined += 1
+}
+
+func (p *networkPartition) OnBlockGenerated(sim *Simulation, slot *Slot) error {
+	if !slot.Proposer.IsMalicious {
+		return nil
+	}
+	fmt.Fprintln(sim.out, "EVIL PROPOSER DOING WORK")
+	newBlockTwo, err := sim.generateBlock(slot.Proposer)
+	if err != nil {
+		return err
+	}
+	if !sim.forked {
+		slot.Proposals = append(slot.Proposals, &Proposal{Block: newBlockTwo, Proposer: slot.Proposer})
+	}
+	return nil
+}
+
+func (p *networkPartition) OnBroadcast(sim *Simulation, slot *Slot) {
+	//check what group proposer is in
+	proposerGroup := 1
+	if slices.Contains(p.groups[0], slot.Proposer) {
+		proposerGroup = 0
+	}
+
+	//chain is forked
+	if sim.forked {
+		fmt.Fprintln(sim.out, "Chain is forked")
+		//broadcast the verified transactions to only right branch-- branch with proposer
+		slot.Proposals[0].Recipients = p.side(sim, proposerGroup, true)
+		slot.Proposals[0].Forced = true
+		return
+	}
+
+	//short range attack
+	if len(slot.Proposals) == 2 {
+		slot.Proposals[0].Recipients = p.side(sim, proposerGroup, true)
+		slot.Proposals[0].Forced = true
+		slot.Proposals[1].Recipients = p.side(sim, proposerGroup, false)
+		slot.Proposals[1].Forced = true
+		if slot.Proposals[0].Accepted && slot.Proposals[1].Accepted {
+			sim.forked = true
+			sim.forkProposer = slot.Proposer
+		}
+	}
+}
+
+// side lists the validators in group, or outside it unless inGroup
+func (p *networkPartition) side(sim *Simulation, group int, inGroup bool) []*Validator {
+	side := make([]*Validator, 0)
+	for _, validator := range sim.validators {
+		if slices.Contains(p.groups[group], validator) == inGroup {
+			side = append(side, validator)
+		}
+	}
+	return side
+}
+
+// balanceAttack starts the network on two forks, each seen by half of the
+// honest and half of the malicious validators. Malicious committee members
+// vote for blocks extending the shorter fork so neither pulls ahead and
+// longest chain consensus keeps being delayed. Under lmd_ghost, malicious
+// validators attest to the lighter fork instead, keeping their weights even.
+type balanceAttack struct {
+	baseAttack
+	fork                          []Block
+	honestJoined, maliciousJoined int
+}
+
+func (b *balanceAttack) Setup(sim *Simulation) {
+	// create initial fork
+	t := sim.clock.Now()
+	genesisBlockFork := Block{}
+	genesisBlockFork = Block{Index: 1, Timestamp: t.String(), Transactions: []Transaction{}, Hash: calculateBlockHash(genesisBlockFork), PrevHash: "", Validator: ""}
+	b.fork = append(b.fork, genesisBlockFork)
+}
+
+func (b *balanceAttack) OnValidatorJoin(sim *Simulation, validator *Validator) {
+	// make only half of the validators see one side of fork
+	viewForkedChain := false
+	if validator.IsMalicious {
+		viewForkedChain = b.maliciousJoined < sim.scenario.NumMal/2
+		b.maliciousJoined += 1
+	} else {
+		viewForkedChain = b.honestJoined <= (sim.scenario.NumValidators-sim.scenario.NumMal)/2
+		b.honestJoined += 1
+	}
+	if viewForkedChain {
+		validator.Blockchain = make([]Block, len(b.fork))
+		copy(validator.Blockchain, b.fork)
+	}
+}
+
+func (b *balanceAttack) OnVote(sim *Simulation, validator *Validator, block Block, valid bool) bool {
+	if !validator.IsMalicious {
+		return valid
+	}
+
+	// find length of the shorter fork
+	shorterForkLength := math.MaxInt32
+	for _, validator := range sim.validators {
+		if len(validator.Blockchain) < shorterForkLength {
+			shorterForkLength = len(validator.Blockchain)
+		}
+	}
+	//vote for the block if it balances the forks
+	return len(sim.proposer.Blockchain) == shorterForkLength
+}
+
+// OnVotesCounted also requires a strict majority of the committee, so a
+// split committee cannot settle which fork grows
+func (b *balanceAttack) OnVotesCounted(sim *Simulation, slot *Slot) {
+	slot.Proposals[0].Accepted = slot.Proposals[0].Accepted && slot.Votes.Valid > len(slot.Committee)/2
+	if len(slot.Proposals) > 1 {
+		slot.Proposals[1].Accepted = slot.Proposals[1].Accepted && slot.Votes.ValidTwo > len(slot.Committee)/2
+	}
+}
+
+func (b *balanceAttack) OnAttest(sim *Simulation, validator *Validator, head Block) Block {
+	if !validator.IsMalicious {
+		return head
+	}
+	branches := sim.ghost.split(sim)
+	if len(branches) < 2 {
+		return head
+	}
+	lighter := branches[0]
+	for _, branch := range branches[1:] {
+		if branch.weight < lighter.weight {
+			lighter = branch
+		}
+	}
+	//attest to nothing rather than to a branch no validator holds
+	tip, ok := sim.ghost.tip(sim, lighter.hash)
+	if !ok {
+		return Block{}
+	}
+	return tip
+}
+
+// OnConsensus delays consensus unless the longest chain leads every other
+// fork by more than one block. Under lmd_ghost, it delays consensus unless
+// the heaviest fork leads every other by more than the stake of malicious
+// validators, who could otherwise move their attestations and tip the
+// balance the other way. Otherwise the chain chosen so far wins.
+func (b *balanceAttack) OnConsensus(sim *Simulation, chosen *Validator) *Validator {
+	if sim.ghost != nil {
+		branches := sim.ghost.split(sim)
+		if len(branches) < 2 {
+			return chosen
+		}
+		first, second := math.Inf(-1), math.Inf(-1)
+		for _, branch := range branches {
+			if branch.weight > first {
+				first, second = branch.weight, first
+			} else if branch.weight > second {
+				second = branch.weight
+			}
+		}
+		if first-second <= totalStake(sim.malValidators) {
+			return nil
+		}
+		return chosen
+	}
+
+	longestLength := -1
+	secondLongestLength := -1
+	var longestValidator *Validator = nil
+	for _, validator := range sim.validators {
+		// + 1 to check for second longest chain
+		if len(validator.Blockchain)+1 >= longestLength {
+			if longestLength == -1 && len(validator.Blockchain) > longestLength {
+				longestValidator = validator
+				longestLength = len(validator.Blockchain)
+				continue
+			}
+
+			longestValidatorLastBlock := longestValidator.Blockchain[len(longestValidator.Blockchain)-1]
+			curValidatorLastBlock := validator.Blockchain[len(validator.Blockchain)-1]
+
+			if longestValidatorLastBlock.Hash != curValidatorLastBlock.Hash {
+				if len(validator.Blockchain) > longestLength {
+					secondLongestLength = longestLength
+					longestValidator = validator
+					longestLength = len(validator.Blockchain)
+				} else {
+					secondLongestLength = len(validator.Blockchain)
+				}
+			}
+		}
+	}
+	if longestLength-secondLongestLength <= 1 {
+		return nil
+	}
+	return chosen
+}
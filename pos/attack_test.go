@@ -0,0 +1,77 @@
+package pos
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAttacksReachTheirHeadlineMetric(t *testing.T) {
+	tests := []struct {
+		attack         string
+		blockchainType string
+		check          func(r SecurityReport) bool
+	}{
+		{"network_partition", "", func(r SecurityReport) bool { return r.Partition.ForkedSlots > 0 }},
+		{"balance", "", func(r SecurityReport) bool { return r.DelayedConsensusRounds > 0 }},
+		{"targeted_dos", "", func(r SecurityReport) bool { return r.Dos.DosTargets > 0 }},
+		{"vote_buying", "dpos", func(r SecurityReport) bool { return r.VoteBuying.VotesBought > 0 }},
+		{"nothing_at_stake", "", func(r SecurityReport) bool { return r.NothingAtStake.NothingAtStakeBlocks > 0 }},
+		{"selfish_proposing", "", func(r SecurityReport) bool { return r.Selfish.WithheldBlocks > 0 }},
+		{"censorship", "", func(r SecurityReport) bool {
+			return r.Censorship.CensoredTransactions > 0 &&
+				r.Censorship.MeanTargetedInclusionSlots > r.Censorship.MeanOtherInclusionSlots
+		}},
+		{"network_partition+double_spend", "", func(r SecurityReport) bool { return r.DoubleSpend.DoubleSpendsBothConfirmed > 0 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.attack, func(t *testing.T) {
+			s := testScenario(42)
+			s.NumSlots = 60
+			s.Attack = tt.attack
+			if tt.blockchainType != "" {
+				s.BlockchainType = tt.blockchainType
+			}
+			report := simulate(t, s).Evaluation.Security
+			if !tt.check(report) {
+				t.Errorf("attack had no effect: %+v", report)
+			}
+		})
+	}
+}
+
+func TestNoAttackLeavesAttackReportsEmpty(t *testing.T) {
+	s := testScenario(42)
+	s.Attack = "none"
+	report := simulate(t, s).Evaluation.Security
+	if report.Partition != nil || report.Dos != nil || report.VoteBuying != nil || report.LongRange != nil ||
+		report.NothingAtStake != nil || report.Selfish != nil || report.Censorship != nil || report.DoubleSpend != nil {
+		t.Errorf("attack reports set without an attack: %+v", report)
+	}
+}
+
+func TestInclusionsFollowTheSettledTransactions(t *testing.T) {
+	s := testScenario(1)
+	s.NumSlots = 60
+	s.Attack = "selfish_proposing+censorship"
+	sim := newTestSimulation(t, s)
+	results, err := sim.Simulate(context.Background())
+	if err != nil {
+		t.Fatalf("Simulate: %v", err)
+	}
+	if results.Evaluation.Security.Reorgs == 0 {
+		t.Fatal("consensus dropped no blocks")
+	}
+	settled := make(map[*User]int)
+	for _, applied := range sim.applied {
+		settled[applied.transaction.Sender]++
+	}
+	for _, user := range sim.users {
+		if user.included != settled[user] {
+			t.Errorf("%s: %d transactions included, %d settled", user.Name, user.included, settled[user])
+		}
+	}
+	r := results.Evaluation.Security.Censorship
+	if r.TargetedIncluded+r.OtherIncluded != len(sim.applied) {
+		t.Errorf("%d transactions included, %d settled", r.TargetedIncluded+r.OtherIncluded, len(sim.applied))
+	}
+}
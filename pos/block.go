@@ -1,6 +1,7 @@
 package pos
 
 import (
+	"crypto/ed25519"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
@@ -13,7 +14,9 @@ type Block struct {
 	Hash         string
 	PrevHash     string
 	Validator    string
-	IsMalicious  bool
+	// The proposer's signature of Hash
+	Signature   string
+	IsMalicious bool
 }
 
 // SHA256 hasing
@@ -27,9 +30,28 @@ func calculateHash(s string) string {
 
 // calculateBlockHash returns the hash of all block information
 func calculateBlockHash(block Block) string {
-	record := fmt.Sprintf("%d%s%s", block.Index, block.Timestamp, block.PrevHash)
+	record := fmt.Sprintf("%d%s%s%s", block.Index, block.Timestamp, block.PrevHash, block.Validator)
 	for _, transaction := range block.Transactions {
-		record += fmt.Sprintf("%d%p%p%s%f", transaction.ID, transaction.Sender, transaction.Receiver, transaction.Signature, transaction.Reward)
+		record += fmt.Sprintf("%d%s%s%s%f", transaction.ID, transaction.Sender.Address, transaction.recipient(), transaction.Signature, transaction.Reward)
 	}
 	return calculateHash(record)
 }
+
+// signBlock signs the hash of block with privateKey
+func signBlock(block *Block, privateKey ed25519.PrivateKey) {
+	block.Signature = hex.EncodeToString(ed25519.Sign(privateKey, []byte(block.Hash)))
+}
+
+// isBlockSigned checks that block was signed by the validator it names. The
+// genesis block and other blocks naming no validator need no signature.
+func (sim *Simulation) isBlockSigned(block Block) bool {
+	if block.Validator == "" {
+		return true
+	}
+	validator := sim.validatorByAddress(block.Validator)
+	if validator == nil {
+		return false
+	}
+	signature, err := hex.DecodeString(block.Signature)
+	return err == nil && ed25519.Verify(validator.PublicKey, []byte(block.Hash), signature)
+}
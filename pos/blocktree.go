@@ -0,0 +1,49 @@
+package pos
+
+// blockTree stores every block validators have held, linked to its parent,
+// including the blocks of forks consensus since dropped. Blocks whose parent
+// is unknown, such as the genesis block, hang from the root "".
+type blockTree struct {
+	// Blocks by hash
+	blocks map[string]Block
+	// Hashes of the children of every block in the order they were added, by
+	// hash of the parent
+	children map[string][]string
+}
+
+func newBlockTree() *blockTree {
+	return &blockTree{
+		blocks:   make(map[string]Block),
+		children: make(map[string][]string),
+	}
+}
+
+// add stores block unless the tree already holds it
+func (t *blockTree) add(block Block) {
+	if _, ok := t.blocks[block.Hash]; ok {
+		return
+	}
+	t.blocks[block.Hash] = block
+	parent := block.PrevHash
+	if _, ok := t.blocks[parent]; !ok {
+		parent = ""
+	}
+	t.children[parent] = append(t.children[parent], block.Hash)
+}
+
+// addChain stores every block of chain
+func (t *blockTree) addChain(chain []Block) {
+	for _, block := range chain {
+		t.add(block)
+	}
+}
+
+// parent returns the hash of the parent of the block with hash, or "" at the
+// root
+func (t *blockTree) parent(hash string) string {
+	parent := t.blocks[hash].PrevHash
+	if _, ok := t.blocks[parent]; !ok {
+		return ""
+	}
+	return parent
+}
@@ -0,0 +1,294 @@
+package pos
+
+import (
+	"fmt"
+	"sort"
+)
+
+// casperFFG is a Casper FFG finality gadget on top of longest chain
+// consensus. Every block at a height divisible by the epoch length is a
+// checkpoint. At the end of every epoch, validators attest to a link from
+// the highest justified checkpoint on their chain, the source, to the highest
+// checkpoint on their chain, the target. A link voted by more than 2/3 of the
+// stake justifies its target, and a justified checkpoint whose direct child
+// checkpoint is justified from it is finalized. Fork choice never leaves the
+// highest justified checkpoint, so finalized blocks are never reverted.
+// Attesters casting two votes for one target epoch, or a vote surrounding
+// another, are slashed and stop attesting. Honest validators never do either,
+// malicious validators attest to the checkpoint of every fork they see.
+type casperFFG struct {
+	epochLength int
+	// Justified and finalized checkpoints, by hash
+	justified map[string]checkpoint
+	finalized map[string]checkpoint
+	// Highest justified and finalized checkpoints
+	lastJustified, lastFinalized checkpoint
+	// Every attestation of every validator, by address
+	attestations map[string][]attestation
+	// Votes for every link, by source and target hash
+	links map[[2]string]*link
+	// Attesters caught double or surround voting
+	slashed map[*Validator]bool
+}
+
+// checkpoint is a block at the start of an epoch
+type checkpoint struct {
+	hash  string
+	index int
+	epoch int
+}
+
+// attestation is a validator's vote for the link from source to target
+type attestation struct {
+	source, target checkpoint
+}
+
+// link is a source and target checkpoint and the addresses of the
+// validators that voted for it
+type link struct {
+	attestation
+	voters map[string]bool
+}
+
+// newCasperFFG returns the gadget with the genesis block justified and
+// finalized
+func newCasperFFG(epochLength int, genesis Block) *casperFFG {
+	root := checkpoint{hash: genesis.Hash, index: genesis.Index}
+	return &casperFFG{
+		epochLength:   epochLength,
+		justified:     map[string]checkpoint{root.hash: root},
+		finalized:     map[string]checkpoint{root.hash: root},
+		lastJustified: root,
+		lastFinalized: root,
+		attestations:  make(map[string][]attestation),
+		links:         make(map[[2]string]*link),
+		slashed:       make(map[*Validator]bool),
+	}
+}
+
+// target returns the highest checkpoint on chain
+func (f *casperFFG) target(chain []Block) (checkpoint, bool) {
+	for i := len(chain) - 1; i >= 0; i-- {
+		if chain[i].Index%f.epochLength == 0 {
+			return checkpoint{hash: chain[i].Hash, index: chain[i].Index, epoch: chain[i].Index / f.epochLength}, true
+		}
+	}
+	return checkpoint{}, false
+}
+
+// source returns the highest justified checkpoint on chain
+func (f *casperFFG) source(chain []Block) (checkpoint, bool) {
+	var source checkpoint
+	found := false
+	for _, block := range chain {
+		if cp, ok := f.justified[block.Hash]; ok && (!found || cp.epoch > source.epoch) {
+			source = cp
+			found = true
+		}
+	}
+	return source, found
+}
+
+// attest has every validator that has not been slashed attest, then
+// justifies and finalizes the checkpoints with enough votes
+func (f *casperFFG) attest(sim *Simulation) {
+	//the chains of the distinct forks validators see, by target hash
+	forks := make(map[string][]Block)
+	forkTargets := make([]string, 0)
+	for _, validator := range sim.validators {
+		target, ok := f.target(validator.Blockchain)
+		if !ok {
+			continue
+		}
+		if _, seen := forks[target.hash]; !seen {
+			forks[target.hash] = validator.Blockchain
+			forkTargets = append(forkTargets, target.hash)
+		}
+	}
+
+	for _, validator := range sim.validators {
+		if f.slashed[validator] {
+			continue
+		}
+		chains := [][]Block{validator.Blockchain}
+		if validator.IsMalicious {
+			chains = chains[:0]
+			for _, hash := range forkTargets {
+				chains = append(chains, forks[hash])
+			}
+		}
+		for _, chain := range chains {
+			target, ok := f.target(chain)
+			if !ok {
+				continue
+			}
+			source, ok := f.source(chain)
+			if !ok || target.epoch <= source.epoch {
+				continue
+			}
+			f.vote(sim, validator, attestation{source: source, target: target})
+		}
+	}
+	f.justify(sim)
+}
+
+// vote records validator's attestation and slashes it if the attestation
+// conflicts with an earlier one. Honest validators never cast a vote that
+// would get them slashed.
+func (f *casperFFG) vote(sim *Simulation, validator *Validator, vote attestation) {
+	doubleVote, surroundVote := false, false
+	for _, earlier := range f.attestations[validator.Address] {
+		if earlier == vote {
+			return
+		}
+		if earlier.target.epoch == vote.target.epoch {
+			doubleVote = true
+		}
+		if surrounds(earlier, vote) || surrounds(vote, earlier) {
+			surroundVote = true
+		}
+	}
+	if !validator.IsMalicious && (doubleVote || surroundVote) {
+		return
+	}
+	f.attestations[validator.Address] = append(f.attestations[validator.Address], vote)
+
+	if doubleVote || surroundVote {
+		if doubleVote {
+			fmt.Fprintf(sim.out, "Attester %s slashed for voting twice in epoch %d\n", validator.Address[:3], vote.target.epoch)
+			sim.security.Casper.DoubleVotes++
+		} else {
+			fmt.Fprintf(sim.out, "Attester %s slashed for a surround vote\n", validator.Address[:3])
+			sim.security.Casper.SurroundVotes++
+		}
+		f.slashed[validator] = true
+		sim.security.Casper.SlashedAttesters++
+		validator.Stake *= sim.scenario.SlashRatio
+		return
+	}
+
+	key := [2]string{vote.source.hash, vote.target.hash}
+	if f.links[key] == nil {
+		f.links[key] = &link{attestation: vote, voters: make(map[string]bool)}
+	}
+	f.links[key].voters[validator.Address] = true
+}
+
+// surrounds reports whether vote a surrounds vote b
+func surrounds(a attestation, b attestation) bool {
+	return a.source.epoch < b.source.epoch && b.target.epoch < a.target.epoch
+}
+
+// justify justifies the targets of links from a justified source voted by
+// more than 2/3 of the stake of attesters that were not slashed, until no
+// more checkpoints can be justified, and finalizes the sources of links
+// between consecutive epochs
+func (f *casperFFG) justify(sim *Simulation) {
+	total := 0.0
+	for _, validator := range sim.validators {
+		if !f.slashed[validator] {
+			total += validator.Stake
+		}
+	}
+
+	//go through the links in a fixed order so runs are reproducible
+	keys := make([][2]string, 0, len(f.links))
+	for key := range f.links {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+
+	for changed := true; changed; {
+		changed = false
+		for _, key := range keys {
+			link := f.links[key]
+			source, target := link.source, link.target
+			if _, ok := f.justified[source.hash]; !ok {
+				continue
+			}
+			linkStake := 0.0
+			for _, validator := range sim.validators {
+				if link.voters[validator.Address] && !f.slashed[validator] {
+					linkStake += validator.Stake
+				}
+			}
+			if linkStake <= 2*total/3 {
+				continue
+			}
+			if _, ok := f.justified[target.hash]; !ok {
+				f.justified[target.hash] = target
+				sim.security.Casper.JustifiedCheckpoints++
+				fmt.Fprintf(sim.out, "Checkpoint %d justified\n", target.index)
+				if target.epoch > f.lastJustified.epoch {
+					f.lastJustified = target
+				}
+				changed = true
+			}
+			if _, ok := f.finalized[source.hash]; !ok && target.epoch == source.epoch+1 {
+				f.finalized[source.hash] = source
+				sim.security.Casper.FinalizedCheckpoints++
+				fmt.Fprintf(sim.out, "Checkpoint %d finalized\n", source.index)
+				if source.epoch > f.lastFinalized.epoch {
+					f.lastFinalized = source
+				}
+				changed = true
+			}
+		}
+	}
+}
+
+// forkChoice returns chosen if its chain holds the highest justified
+// checkpoint, and otherwise the longest chain that does, so consensus never
+// reverts a justified or finalized checkpoint. It returns nil to delay
+// consensus if no validator's chain holds the checkpoint.
+func (f *casperFFG) forkChoice(sim *Simulation, chosen *Validator) *Validator {
+	if hasBlock(chosen.Blockchain, f.lastJustified.hash) {
+		return chosen
+	}
+	var longestValidator *Validator
+	for _, validator := range sim.validators {
+		if hasBlock(validator.Blockchain, f.lastJustified.hash) && (longestValidator == nil || len(validator.Blockchain) > len(longestValidator.Blockchain)) {
+			longestValidator = validator
+		}
+	}
+	if longestValidator != nil {
+		fmt.Fprintf(sim.out, "Fork choice kept justified checkpoint %d\n", f.lastJustified.index)
+	}
+	return longestValidator
+}
+
+// finalizedLength counts the blocks at the start of chain up to its highest
+// finalized checkpoint
+func (f *casperFFG) finalizedLength(chain []Block) int {
+	for i := len(chain) - 1; i >= 0; i-- {
+		if _, ok := f.finalized[chain[i].Hash]; ok {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// hasBlock reports whether chain holds the block with hash
+func hasBlock(chain []Block, hash string) bool {
+	for _, block := range chain {
+		if block.Hash == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// FinalizedBlockchain returns the part of the certified chain up to its
+// highest finalized checkpoint, which no consensus round can revert. Without
+// a finality gadget nothing is ever final.
+func (sim *Simulation) FinalizedBlockchain() []Block {
+	if sim.finality == nil {
+		return nil
+	}
+	return sim.CertifiedBlockchain[:sim.finality.finalizedLength(sim.CertifiedBlockchain)]
+}
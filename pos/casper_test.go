@@ -0,0 +1,24 @@
+package pos
+
+import "testing"
+
+func TestCasperFinalizesCheckpoints(t *testing.T) {
+	s := testScenario(42)
+	s.NumSlots = 60
+	s.Attack = "none"
+	s.Finality = "casper_ffg"
+	report := simulate(t, s).Evaluation.Security
+	c := report.Casper
+	if c == nil {
+		t.Fatal("no casper metrics reported")
+	}
+	if c.JustifiedCheckpoints == 0 || c.FinalizedCheckpoints == 0 {
+		t.Errorf("no checkpoints justified or finalized: %+v", c)
+	}
+	if c.FinalizedBlocks == 0 || c.FinalizedBlocks > report.TotalBlocks {
+		t.Errorf("%d of %d blocks finalized", c.FinalizedBlocks, report.TotalBlocks)
+	}
+	if c.SlashedAttesters != 0 {
+		t.Errorf("%d attesters slashed without an attack", c.SlashedAttesters)
+	}
+}
@@ -0,0 +1,25 @@
+package pos
+
+import "fmt"
+
+// censorship has malicious proposers leave the transactions of the targeted
+// users, the first censoredUsers to join, out of every block they build and
+// fill it with other transactions of their mempool instead. The transactions
+// stay in the mempools and wait for an honest proposer, so how long they
+// wait depends on how often proposers rotate to honest ones.
+type censorship struct {
+	baseAttack
+}
+
+func (c *censorship) OnTransactionSelected(sim *Simulation, proposer *Validator, transaction Transaction) bool {
+	if !proposer.IsMalicious || !transaction.Sender.targeted {
+		return true
+	}
+	fmt.Fprintf(sim.out, "Proposer %s leaves transaction %d out of its block\n", proposer.Address[:3], transaction.ID)
+	sim.security.Censorship.CensorshipExclusions++
+	if !sim.censored[transaction.ID] {
+		sim.censored[transaction.ID] = true
+		sim.security.Censorship.CensoredTransactions++
+	}
+	return false
+}
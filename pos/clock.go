@@ -0,0 +1,79 @@
+package pos
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for a simulation. Time slots, transaction
+// generation, block timestamps and the evaluation's elapsed time all read it.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+	// WaitUntil returns a channel that receives once the clock reaches t,
+	// and a function that abandons the wait
+	WaitUntil(t time.Time) (<-chan time.Time, func() bool)
+}
+
+// realClock follows the wall clock, so a 5 second slot takes 5 seconds
+type realClock struct{}
+
+// NewRealClock returns a clock that follows the wall clock
+func NewRealClock() Clock {
+	return realClock{}
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) WaitUntil(t time.Time) (<-chan time.Time, func() bool) {
+	timer := time.NewTimer(time.Until(t))
+	return timer.C, timer.Stop
+}
+
+// virtualClock jumps straight to the next event, so slots run as fast as the
+// CPU allows while keeping their spacing in simulated time
+type virtualClock struct {
+	now  time.Time
+	lock sync.Mutex
+}
+
+// NewVirtualClock returns a fast-forwarding clock starting at start
+func NewVirtualClock(start time.Time) Clock {
+	return &virtualClock{now: start}
+}
+
+func (c *virtualClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+// WaitUntil is ready straight away but leaves the clock where it is, so a
+// message the engine handles instead of the event still sees the current
+// time. The engine advances the clock once it fires the event.
+func (c *virtualClock) WaitUntil(t time.Time) (<-chan time.Time, func() bool) {
+	c.lock.Lock()
+	if c.now.After(t) {
+		t = c.now
+	}
+	c.lock.Unlock()
+	ready := make(chan time.Time, 1)
+	ready <- t
+	return ready, func() bool { return false }
+}
+
+// advancer is a clock that only moves forward when told to
+type advancer interface {
+	// advance moves the clock to t, unless it is already past t
+	advance(t time.Time)
+}
+
+func (c *virtualClock) advance(t time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if t.After(c.now) {
+		c.now = t
+	}
+}
@@ -0,0 +1,39 @@
+package pos
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVirtualClockOnlyAdvancesWhenTold(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := NewVirtualClock(start)
+	ready, _ := clock.WaitUntil(start.Add(time.Hour))
+	if at := <-ready; !at.Equal(start.Add(time.Hour)) {
+		t.Errorf("ready at %v, want an hour after the start", at)
+	}
+	if !clock.Now().Equal(start) {
+		t.Errorf("waiting moved the clock to %v", clock.Now())
+	}
+
+	clock.(advancer).advance(start.Add(time.Hour))
+	clock.(advancer).advance(start.Add(time.Minute))
+	if !clock.Now().Equal(start.Add(time.Hour)) {
+		t.Errorf("clock at %v, want an hour after the start", clock.Now())
+	}
+	//a wait for a past time is ready at the current time
+	ready, _ = clock.WaitUntil(start)
+	if at := <-ready; !at.Equal(start.Add(time.Hour)) {
+		t.Errorf("wait for the past ready at %v, want the current time", at)
+	}
+}
+
+func TestRealClockWaits(t *testing.T) {
+	clock := NewRealClock()
+	begin := time.Now()
+	ready, _ := clock.WaitUntil(begin.Add(20 * time.Millisecond))
+	<-ready
+	if waited := time.Since(begin); waited < 20*time.Millisecond {
+		t.Errorf("waited %v, want 20ms", waited)
+	}
+}
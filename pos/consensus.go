@@ -0,0 +1,269 @@
+package pos
+
+import (
+	"fmt"
+	"math"
+)
+
+// ConsensusProtocol is the set of rules a blockchain type plays by: who
+// proposes and who votes on the block of every time slot, how they are
+// rewarded or punished, and which chain wins when validators disagree. The
+// time slot itself, and the attacks played against it, are the same for
+// every protocol.
+type ConsensusProtocol interface {
+	// SelectCommittee returns the validators voting on the block of this slot
+	SelectCommittee(sim *Simulation) []*Validator
+	// SelectProposer returns the validator proposing the block of this slot,
+	// or nil to skip the slot
+	SelectProposer(sim *Simulation, committee []*Validator) *Validator
+	// ProposeBlock returns the block proposer puts to the committee
+	ProposeBlock(sim *Simulation, proposer *Validator) (Block, error)
+	// CollectVotes sends msg to every member of committee, tallies the
+	// replies and decides whether the proposed blocks are accepted
+	CollectVotes(sim *Simulation, committee []*Validator, msg interface{}) Votes
+	// RewardProposer rewards proposer for a block the committee accepted, on
+	// top of the transaction rewards every protocol pays
+	RewardProposer(sim *Simulation, proposer *Validator)
+	// PunishProposer punishes proposer for a block the committee rejected
+	PunishProposer(sim *Simulation, proposer *Validator)
+	// SettleVotes rewards or punishes the members of committee depending on
+	// whether they voted with the outcome
+	SettleVotes(sim *Simulation, committee []*Validator, votes Votes, accepted bool)
+	// ForkChoice returns the validator whose chain every validator adopts at
+	// a consensus round, or nil to delay consensus
+	ForkChoice(sim *Simulation) *Validator
+	// PunishForkProposer punishes the proposer of a fork consensus resolved
+	PunishForkProposer(sim *Simulation, proposer *Validator)
+}
+
+// multiLeaderProtocol is a protocol that may elect several leaders in a time
+// slot. The first proposes the slot's block, and the others propose blocks of
+// their own that skip the committee vote.
+type multiLeaderProtocol interface {
+	// OtherLeaders returns the leaders of this slot besides its proposer
+	OtherLeaders(sim *Simulation) []*Validator
+}
+
+// Votes tallies the replies of a committee on the proposed block, and on the
+// second block of a malicious proposer during a network partition
+type Votes struct {
+	Valid, Invalid       int
+	ValidTwo, InvalidTwo int
+	// How every member voted, by address
+	Ballots map[string]bool
+	// Members that voted for both blocks
+	DoubleVoters []*Validator
+	// Whether the committee accepted the block, and the second block
+	Accepted, AcceptedTwo bool
+	// Whether the protocol commits blocks later rather than accepting or
+	// rejecting them in this slot, and the earlier blocks it committed now
+	Deferred bool
+	Commits  []*Proposal
+}
+
+// newConsensusProtocol returns the protocol of a blockchain type
+func newConsensusProtocol(blockchainType string) (ConsensusProtocol, error) {
+	switch blockchainType {
+	case "pos":
+		return stakeProtocol{}, nil
+	case "slashing":
+		return stakeProtocol{slashing: true}, nil
+	case "reputation":
+		return reputationProtocol{}, nil
+	case "tendermint":
+		return newTendermintProtocol(), nil
+	case "ouroboros":
+		return newOuroborosProtocol(), nil
+	case "hotstuff":
+		return newHotstuffProtocol(), nil
+	case "dpos":
+		return dposProtocol{}, nil
+	case "npos":
+		return dposProtocol{nominated: true}, nil
+	default:
+		return nil, fmt.Errorf("unknown blockchain type %q", blockchainType)
+	}
+}
+
+// baseProtocol is the block proposal, committee vote and longest chain rule
+// the protocols have in common
+type baseProtocol struct{}
+
+func (baseProtocol) ProposeBlock(sim *Simulation, proposer *Validator) (Block, error) {
+	return sim.generateBlock(proposer)
+}
+
+// CollectVotes accepts blocks at least half of the committee found valid
+func (baseProtocol) CollectVotes(sim *Simulation, committee []*Validator, msg interface{}) Votes {
+	//broadcast block to all members of committee
+	validationReplies := make([]interface{}, 0, len(committee))
+	for _, validator := range committee {
+		validationReplies = append(validationReplies, validator.receive(msg))
+	}
+
+	// Process validation results
+	votes := Votes{Ballots: make(map[string]bool)}
+	for i, validator := range committee {
+		msg := validationReplies[i]
+		switch msg := msg.(type) { // Use type assertion to determine the type of the received message
+		case ValidationStatusMessage:
+			votes.Ballots[validator.Address] = msg.isValid
+			if msg.isValid == true {
+				votes.Valid++
+			} else {
+				votes.Invalid++
+			}
+		case ValidationShortAttackStatusMessage:
+			votes.Ballots[validator.Address] = msg.isValidTwo
+			if msg.isValid == true {
+				votes.Valid++
+			} else {
+				votes.Invalid++
+			}
+			if msg.isValidTwo == true {
+				votes.ValidTwo++
+			} else {
+				votes.InvalidTwo++
+			}
+			if msg.isValid && msg.isValidTwo {
+				votes.DoubleVoters = append(votes.DoubleVoters, validator)
+			}
+		default:
+			fmt.Fprintf(sim.out, "Received an unknown struct: %+v\n", msg)
+			fmt.Fprintf(sim.out, "%T\n", msg)
+		}
+	}
+	votes.Accepted = votes.Valid >= len(committee)/2
+	votes.AcceptedTwo = votes.ValidTwo >= len(committee)/2
+	return votes
+}
+
+// ForkChoice picks the longest chain
+func (baseProtocol) ForkChoice(sim *Simulation) *Validator {
+	longestLength := -1
+	var longestValidator *Validator = nil
+	for _, validator := range sim.validators {
+		if len(validator.Blockchain) > longestLength {
+			longestValidator = validator
+			longestLength = len(validator.Blockchain)
+		}
+	}
+	return longestValidator
+}
+
+// stakeProtocol draws the committee, and the proposer from it, weighted by
+// stake, or lets validators select themselves under vrf sortition. With
+// slashing, proposers of rejected blocks or forks and committee members
+// voting against the outcome lose part of their stake.
+type stakeProtocol struct {
+	baseProtocol
+	slashing bool
+}
+
+func (p stakeProtocol) SelectCommittee(sim *Simulation) []*Validator {
+	var committee []*Validator
+	if sim.scenario.Sortition == "vrf" {
+		committee = sim.vrfCommittee(sim.validators, sim.committeeSize)
+		fmt.Fprintf(sim.out, "%d validators selected themselves for the committee\n", len(committee))
+	} else {
+		//randomly choose new committee of a third of all validators who will validate the new block
+		committee = sim.chooseValidationCommittee(sim.validators, sim.committeeSize)
+		fmt.Fprintln(sim.out, "New validation committee chosen")
+	}
+	for _, commit := range committee {
+		commit.committeeCount += 1
+	}
+	return committee
+}
+
+func (p stakeProtocol) SelectProposer(sim *Simulation, committee []*Validator) *Validator {
+	if sim.scenario.Sortition == "vrf" {
+		return sim.vrfProposer(committee)
+	}
+	//Choose a new block proposer based on stake
+	return sim.chooseBlockProposer(committee)
+}
+
+func (p stakeProtocol) RewardProposer(sim *Simulation, proposer *Validator) {}
+
+func (p stakeProtocol) PunishProposer(sim *Simulation, proposer *Validator) {
+	if p.slashing {
+		proposer.Stake *= sim.scenario.SlashRatio
+	}
+}
+
+func (p stakeProtocol) SettleVotes(sim *Simulation, committee []*Validator, votes Votes, accepted bool) {
+	//punish validators who voted against the majority
+	for _, validator := range committee {
+		if votes.Ballots[validator.Address] == accepted {
+			continue
+		}
+		if accepted {
+			fmt.Fprintln(sim.out, "VALIDATED FALSE WHEN IT WAS TRUE")
+		} else {
+			fmt.Fprintln(sim.out, "VALIDATED TRUE WHEN IT WAS FALSE")
+		}
+		if p.slashing {
+			validator.Stake *= sim.scenario.SlashRatio
+		}
+	}
+}
+
+func (p stakeProtocol) PunishForkProposer(sim *Simulation, proposer *Validator) {
+	if p.slashing {
+		proposer.Stake *= sim.scenario.SlashRatio
+	}
+}
+
+// reputationProtocol has validators elect delegates by reputation, who take
+// turns proposing and form the committee. Proposers and delegates gain
+// reputation for blocks accepted and votes with the outcome, and lose it
+// otherwise.
+type reputationProtocol struct {
+	baseProtocol
+}
+
+func (p reputationProtocol) SelectCommittee(sim *Simulation) []*Validator {
+	//Choose new delegates
+	if sim.delegateCounter == 2*sim.delegateSize {
+		sim.delegateCounter = 0
+		sim.delegates = sim.chooseDelegates(sim.validators, sim.delegateSize)
+		fmt.Fprintln(sim.out, "New delegates chosen")
+	}
+	return sim.delegates
+}
+
+func (p reputationProtocol) SelectProposer(sim *Simulation, committee []*Validator) *Validator {
+	if len(committee) == 0 {
+		//elect again next slot, validators may have joined
+		sim.delegateCounter = 2 * sim.delegateSize
+		return nil
+	}
+	//Choose next sequential block proposer from delegates
+	proposer := committee[sim.delegateCounter%len(committee)]
+	sim.delegateCounter += 1
+	return proposer
+}
+
+func (p reputationProtocol) RewardProposer(sim *Simulation, proposer *Validator) {
+	proposer.reputation = math.Min(100, proposer.reputation+1)
+}
+
+func (p reputationProtocol) PunishProposer(sim *Simulation, proposer *Validator) {
+	proposer.reputation *= sim.scenario.ReputationSlashRatio
+}
+
+func (p reputationProtocol) SettleVotes(sim *Simulation, committee []*Validator, votes Votes, accepted bool) {
+	//punish validators who voted against the majority
+	for _, validator := range committee {
+		if votes.Ballots[validator.Address] != accepted {
+			validator.reputation *= sim.scenario.VoteReputationSlashRatio
+		} else {
+			validator.reputation = math.Min(100, 1+validator.reputation)
+		}
+	}
+}
+
+func (p reputationProtocol) PunishForkProposer(sim *Simulation, proposer *Validator) {
+	proposer.reputation *= sim.scenario.ReputationSlashRatio
+}
@@ -0,0 +1,88 @@
+package pos
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/exp/slices"
+)
+
+// targetedDoS knocks dosBudget honest validators offline every slot, so they
+// neither propose nor vote. With central sortition the committee and
+// proposer are known as soon as they are drawn, so the attacker takes down
+// the honest proposer and honest committee members, skipping slots and
+// handing committees to malicious members. Under vrf sortition no one knows
+// who was selected until the proof comes with the vote, too late to act on
+// it, so the attacker can only take down honest validators at random.
+type targetedDoS struct {
+	baseAttack
+	// Whether the proposer of the slot in progress is offline
+	proposerOffline bool
+}
+
+func (d *targetedDoS) OnProposerSelected(sim *Simulation, slot *Slot) {
+	targets := make([]*Validator, 0, sim.scenario.DosBudget)
+	if sim.scenario.Sortition == "vrf" && slices.Contains(vrfBlockchainTypes, sim.blockchainType) {
+		honest := make([]*Validator, 0)
+		for _, validator := range sim.validators {
+			if !validator.IsMalicious {
+				honest = append(honest, validator)
+			}
+		}
+		for _, i := range sim.rng.Perm(len(honest)) {
+			if len(targets) == sim.scenario.DosBudget {
+				break
+			}
+			targets = append(targets, honest[i])
+		}
+	} else {
+		for _, validator := range append([]*Validator{slot.Proposer}, slot.Committee...) {
+			if len(targets) < sim.scenario.DosBudget && !validator.IsMalicious && !slices.Contains(targets, validator) {
+				targets = append(targets, validator)
+			}
+		}
+	}
+	sim.security.Dos.DosTargets += len(targets)
+	for _, target := range targets {
+		if target == slot.Proposer || slices.Contains(slot.Committee, target) {
+			sim.security.Dos.DosHits++
+		}
+	}
+
+	capturedBefore := maliciousMajority(slot.Committee)
+	committee := make([]*Validator, 0, len(slot.Committee))
+	for _, validator := range slot.Committee {
+		if !slices.Contains(targets, validator) {
+			committee = append(committee, validator)
+		}
+	}
+	slot.Committee = committee
+	if !capturedBefore && maliciousMajority(committee) {
+		sim.security.Dos.CapturedCommittees++
+	}
+
+	d.proposerOffline = slices.Contains(targets, slot.Proposer)
+	if d.proposerOffline {
+		sim.security.Dos.DosSkippedSlots++
+	}
+	fmt.Fprintf(sim.out, "DoS knocked %d validators offline, %d committee members left\n", len(targets), len(committee))
+}
+
+func (d *targetedDoS) OnBlockGenerated(sim *Simulation, slot *Slot) error {
+	if d.proposerOffline {
+		return errors.New("Proposer knocked offline, slot skipped")
+	}
+	return nil
+}
+
+// maliciousMajority reports whether malicious validators are more than half
+// of committee
+func maliciousMajority(committee []*Validator) bool {
+	malicious := 0
+	for _, validator := range committee {
+		if validator.IsMalicious {
+			malicious++
+		}
+	}
+	return len(committee) > 0 && malicious > len(committee)/2
+}
@@ -0,0 +1,103 @@
+package pos
+
+import "fmt"
+
+// doubleSpendReward is the reward a double spender pays on each transaction,
+// more than any other user pays so proposers take its transactions first
+const doubleSpendReward = 5.0
+
+// doubleSpend has the first user by name spend its balance twice while the
+// chain is forked, such as during a network partition. It signs two
+// conflicting transactions moving all it holds to two other users and sends
+// each only to the validators on one of the two branches, so each branch
+// sees a valid transaction. It records which transactions an accepted block
+// confirmed, and once consensus resolved the fork, reverting the transaction
+// of the dropped branch, which one the certified chain kept.
+type doubleSpend struct {
+	baseAttack
+	// Conflicting transactions of the fork in progress, one per branch
+	spends    []Transaction
+	confirmed [2]bool
+}
+
+func (d *doubleSpend) OnProposerSelected(sim *Simulation, slot *Slot) {
+	heads := sim.chainHeads()
+	switch {
+	case d.spends == nil && len(heads) > 1:
+		d.spend(sim, heads[:2])
+	case d.spends != nil && len(heads) == 1:
+		d.settle(sim)
+	}
+}
+
+// spend sends a conflicting transaction to the validators on each branch
+// ending in heads
+func (d *doubleSpend) spend(sim *Simulation, heads []Block) {
+	users := sim.sortedUsers()
+	if len(users) < 2 {
+		return
+	}
+	sender := users[0]
+	amount := sender.Balance - sender.locked - doubleSpendReward
+	if amount <= 0 {
+		return
+	}
+	for i, head := range heads {
+		branch := make([]*Validator, 0)
+		for _, validator := range sim.validators {
+			if validator.Blockchain[len(validator.Blockchain)-1].Hash == head.Hash {
+				branch = append(branch, validator)
+			}
+		}
+		receiver := users[1+i%(len(users)-1)]
+		transaction := generateTransaction(sim.newTransactionID(), sender, receiver, amount, doubleSpendReward)
+		fmt.Fprintf(sim.out, "User %s double spends %.2f to %s on branch %d\n", sender.Name, amount, receiver.Name, i)
+		sender.broadcastTransactionTo(transaction, branch)
+		d.spends = append(d.spends, transaction)
+	}
+	d.confirmed = [2]bool{}
+	sim.security.DoubleSpend.DoubleSpends++
+}
+
+// settle records which of the conflicting transactions the certified chain
+// kept once the fork is resolved
+func (d *doubleSpend) settle(sim *Simulation) {
+	if d.confirmed[0] && d.confirmed[1] {
+		sim.security.DoubleSpend.DoubleSpendsBothConfirmed++
+		sim.security.DoubleSpend.DoubleSpentAmount += d.spends[0].Amount
+	}
+	survivor := -1
+	for i, transaction := range d.spends {
+		if hasTransaction(sim.CertifiedBlockchain, transaction.ID) {
+			survivor = i
+		}
+	}
+	switch survivor {
+	case 0:
+		sim.security.DoubleSpend.DoubleSpendsFirstSurvived++
+	case 1:
+		sim.security.DoubleSpend.DoubleSpendsSecondSurvived++
+	default:
+		sim.security.DoubleSpend.DoubleSpendsLost++
+	}
+	fmt.Fprintf(sim.out, "Double spend resolved, confirmed on branches %t and %t, transaction %d survived\n", d.confirmed[0], d.confirmed[1], survivor)
+	d.spends = nil
+}
+
+func (d *doubleSpend) OnBlockAccepted(sim *Simulation, proposal *Proposal) {
+	for i, transaction := range d.spends {
+		d.confirmed[i] = d.confirmed[i] || hasTransaction([]Block{proposal.Block}, transaction.ID)
+	}
+}
+
+// hasTransaction reports whether chain holds the transaction with id
+func hasTransaction(chain []Block, id int) bool {
+	for _, block := range chain {
+		for _, transaction := range block.Transactions {
+			if transaction.ID == id {
+				return true
+			}
+		}
+	}
+	return false
+}
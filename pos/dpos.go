@@ -0,0 +1,121 @@
+package pos
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// dposProtocol is delegated proof of stake. Users lock tokens behind the
+// validator they vote for with vote transactions, which only count once a
+// block includes them, and a new vote replaces the last one. Every
+// 2*delegateSize slots the delegateSize validators with the most tokens
+// behind them are elected delegates, who take turns proposing and form the
+// committee. Delegates are not slashed, voters replace those they are
+// unhappy with. Under npos users nominate several validators instead and the
+// delegates are elected by sequential Phragmén.
+type dposProtocol struct {
+	baseProtocol
+	nominated bool
+}
+
+func (p dposProtocol) SelectCommittee(sim *Simulation) []*Validator {
+	if sim.delegateCounter == 2*sim.delegateSize {
+		sim.delegateCounter = 0
+		if p.nominated {
+			sim.delegates = sim.electPhragmen(sim.delegateSize)
+		} else {
+			sim.delegates = sim.electDelegates(sim.delegateSize)
+		}
+	}
+	return sim.delegates
+}
+
+func (p dposProtocol) SelectProposer(sim *Simulation, committee []*Validator) *Validator {
+	if len(committee) == 0 {
+		//elect again next slot, validators may have joined
+		sim.delegateCounter = 2 * sim.delegateSize
+		return nil
+	}
+	//Choose next sequential block proposer from delegates
+	proposer := committee[sim.delegateCounter%len(committee)]
+	sim.delegateCounter += 1
+	return proposer
+}
+
+// RewardProposer leaves delegates with their transaction rewards only
+func (p dposProtocol) RewardProposer(sim *Simulation, proposer *Validator) {}
+
+// PunishProposer does nothing, voters decide whether to keep a delegate
+func (p dposProtocol) PunishProposer(sim *Simulation, proposer *Validator) {}
+
+func (p dposProtocol) SettleVotes(sim *Simulation, committee []*Validator, votes Votes, accepted bool) {
+}
+
+func (p dposProtocol) PunishForkProposer(sim *Simulation, proposer *Validator) {}
+
+// castDelegateVote applies a vote transaction a block included, moving the
+// sender's locked tokens to its candidates
+func (sim *Simulation) castDelegateVote(transaction Transaction) {
+	user := transaction.Sender
+	user.nominations = transaction.Candidates
+	user.locked = transaction.Amount
+	sim.security.Elections.VoteTransactions++
+	short := make([]string, len(transaction.Candidates))
+	for i, candidate := range transaction.Candidates {
+		short[i] = candidate[:3]
+	}
+	fmt.Fprintf(sim.out, "%s locks %.2f behind %s\n", user.Name, transaction.Amount, strings.Join(short, ", "))
+}
+
+// delegateVotes adds up the tokens locked behind every validator, by address
+func (sim *Simulation) delegateVotes() map[string]float64 {
+	votes := make(map[string]float64)
+	for _, user := range sim.sortedUsers() {
+		for _, candidate := range user.nominations {
+			votes[candidate] += user.locked
+		}
+	}
+	return votes
+}
+
+// sortedUsers returns the users by name
+func (sim *Simulation) sortedUsers() []*User {
+	names := make([]string, 0, len(sim.users))
+	for name := range sim.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	users := make([]*User, len(names))
+	for i, name := range names {
+		users[i] = sim.users[name]
+	}
+	return users
+}
+
+// electDelegates returns the delegateSize validators with the most tokens
+// behind them, breaking ties by stake and then by the order validators joined
+func (sim *Simulation) electDelegates(delegateSize int) []*Validator {
+	votes := sim.delegateVotes()
+	candidates := make([]*Validator, len(sim.validators))
+	copy(candidates, sim.validators)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if votes[candidates[i].Address] != votes[candidates[j].Address] {
+			return votes[candidates[i].Address] > votes[candidates[j].Address]
+		}
+		return candidates[i].Stake > candidates[j].Stake
+	})
+	//fewer validators than delegates may have joined
+	delegates := candidates
+	if delegateSize < len(candidates) {
+		delegates = candidates[:delegateSize]
+	}
+
+	names := make([]string, len(delegates))
+	for i, delegate := range delegates {
+		names[i] = fmt.Sprintf("%s (%.2f)", delegate.Address[:3], votes[delegate.Address])
+	}
+	fmt.Fprintf(sim.out, "Delegates elected: %s\n", strings.Join(names, ", "))
+	sim.recordElection(delegates, votes)
+	return delegates
+}
@@ -0,0 +1,28 @@
+package pos
+
+import (
+	"io"
+	"testing"
+)
+
+func TestElectionWithFewerValidatorsThanDelegates(t *testing.T) {
+	s := testScenario(1)
+	s.BlockchainType = "dpos"
+	s.DelegateSize = 3
+	sim := newTestSimulation(t, s)
+	if delegates := sim.electDelegates(s.DelegateSize); len(delegates) != 0 {
+		t.Fatalf("%d delegates elected among no validators", len(delegates))
+	}
+	if elections := sim.security.Elections.DelegateElections; elections != 0 {
+		t.Errorf("%d elections counted without delegates", elections)
+	}
+
+	validator := sim.newValidator(io.Discard, 500, false)
+	delegates := sim.electDelegates(s.DelegateSize)
+	if len(delegates) != 1 || delegates[0] != validator {
+		t.Fatalf("delegates elected: %v, want the only validator", delegates)
+	}
+	if elections := sim.security.Elections.DelegateElections; elections != 1 {
+		t.Errorf("%d elections counted, want 1", elections)
+	}
+}
@@ -0,0 +1,127 @@
+package pos
+
+import (
+	"container/heap"
+	"context"
+	"time"
+)
+
+// event is an action that fires at a point in time
+type event struct {
+	at   time.Time
+	seq  int
+	fire func()
+}
+
+// eventQueue orders events by time, breaking ties by scheduling order
+type eventQueue []*event
+
+func (q eventQueue) Len() int { return len(q) }
+
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].at.Equal(q[j].at) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].at.Before(q[j].at)
+}
+
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *eventQueue) Push(x interface{}) { *q = append(*q, x.(*event)) }
+
+func (q *eventQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// eventEngine is a discrete-event loop that drives time slots, validators and
+// users. Every event fires on the goroutine calling run, so actors never need a
+// connection or a goroutine of their own.
+type eventEngine struct {
+	clock Clock
+	queue eventQueue
+	seq   int
+	// inbox receives actions from other goroutines, e.g. TCP connections
+	inbox chan func()
+	// Events after deadline never fire, unless it is zero
+	deadline time.Time
+	// stopped is closed once run returns
+	stopped chan struct{}
+}
+
+func newEventEngine(clock Clock) *eventEngine {
+	return &eventEngine{
+		clock:   clock,
+		queue:   eventQueue{},
+		inbox:   make(chan func()),
+		stopped: make(chan struct{}),
+	}
+}
+
+// schedule queues fire to run at time at. It must only be called from the
+// engine goroutine or before run starts.
+func (e *eventEngine) schedule(at time.Time, fire func()) {
+	heap.Push(&e.queue, &event{at: at, seq: e.seq, fire: fire})
+	e.seq++
+}
+
+// post hands fire to the engine goroutine, runs it between events and waits
+// for it to finish. It reports false without running fire once the engine has
+// stopped. It must not be called from the engine goroutine.
+func (e *eventEngine) post(fire func()) bool {
+	done := make(chan struct{})
+	select {
+	case e.inbox <- func() {
+		fire()
+		close(done)
+	}:
+	case <-e.stopped:
+		return false
+	}
+	<-done
+	return true
+}
+
+// run fires events in time order until done reports true, the deadline
+// passes or ctx is cancelled. It can only be called once.
+func (e *eventEngine) run(ctx context.Context, done func() bool) {
+	defer close(e.stopped)
+	for !done() {
+		if len(e.queue) == 0 {
+			select {
+			case fire := <-e.inbox:
+				fire()
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+
+		next := e.queue[0]
+		at := next.at
+		pastDeadline := !e.deadline.IsZero() && at.After(e.deadline)
+		if pastDeadline {
+			at = e.deadline
+		}
+		ready, stop := e.clock.WaitUntil(at)
+		select {
+		case fire := <-e.inbox:
+			stop()
+			fire()
+		case <-ctx.Done():
+			stop()
+			return
+		case now := <-ready:
+			if clock, ok := e.clock.(advancer); ok {
+				clock.advance(now)
+			}
+			if pastDeadline {
+				return
+			}
+			heap.Pop(&e.queue)
+			next.fire()
+		}
+	}
+}
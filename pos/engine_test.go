@@ -0,0 +1,74 @@
+package pos
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEngineFiresEventsInTimeOrder(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := NewVirtualClock(start)
+	engine := newEventEngine(clock)
+	fired := make([]string, 0)
+	at := make([]time.Duration, 0)
+	schedule := func(after time.Duration, name string) {
+		engine.schedule(start.Add(after), func() {
+			fired = append(fired, name)
+			at = append(at, clock.Now().Sub(start))
+		})
+	}
+	schedule(3*time.Second, "c")
+	schedule(time.Second, "a")
+	schedule(2*time.Second, "b1")
+	schedule(2*time.Second, "b2")
+
+	engine.run(context.Background(), func() bool { return len(fired) == 4 })
+	want := []string{"a", "b1", "b2", "c"}
+	wantAt := []time.Duration{time.Second, 2 * time.Second, 2 * time.Second, 3 * time.Second}
+	for i := range want {
+		if fired[i] != want[i] || at[i] != wantAt[i] {
+			t.Fatalf("fired %v at %v, want %v at %v", fired, at, want, wantAt)
+		}
+	}
+}
+
+func TestEngineStopsAtTheDeadline(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := NewVirtualClock(start)
+	engine := newEventEngine(clock)
+	engine.deadline = start.Add(2 * time.Second)
+	fired := 0
+	for i := 1; i <= 3; i++ {
+		engine.schedule(start.Add(time.Duration(i)*time.Second), func() { fired++ })
+	}
+	engine.run(context.Background(), func() bool { return false })
+	if fired != 2 {
+		t.Errorf("%d events fired, want the 2 up to the deadline", fired)
+	}
+	if !clock.Now().Equal(engine.deadline) {
+		t.Errorf("clock stopped at %v, want the deadline %v", clock.Now(), engine.deadline)
+	}
+}
+
+func TestEnginePostRunsOnTheEngineGoroutine(t *testing.T) {
+	engine := newEventEngine(NewVirtualClock(time.Unix(0, 0)))
+	posted := false
+	go func() {
+		if !engine.post(func() { posted = true }) {
+			t.Error("post reported the engine stopped while it was running")
+		}
+	}()
+	engine.run(context.Background(), func() bool { return posted })
+	if engine.post(func() {}) {
+		t.Error("post ran an action after the engine stopped")
+	}
+}
+
+func TestEngineStopsWhenCancelled(t *testing.T) {
+	engine := newEventEngine(NewRealClock())
+	engine.schedule(time.Now().Add(time.Hour), func() { t.Error("event fired after cancelling") })
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	engine.run(ctx, func() bool { return false })
+}
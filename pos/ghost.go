@@ -0,0 +1,168 @@
+package pos
+
+import "fmt"
+
+// lmdGhost is the LMD-GHOST fork choice rule of Gasper. At the start of every
+// time slot each validator attests to the head of its chain, and only its
+// latest attestation counts. Starting from the root of the block tree, or
+// from the highest justified checkpoint under casper_ffg, the rule keeps
+// moving to the child whose subtree holds the most attesting stake, breaking
+// ties by the higher hash, until it reaches a leaf, the head.
+type lmdGhost struct {
+	tree *blockTree
+	// Hash of the block every validator last attested to, by address
+	latest map[string]string
+}
+
+func newLMDGhost() *lmdGhost {
+	return &lmdGhost{
+		tree:   newBlockTree(),
+		latest: make(map[string]string),
+	}
+}
+
+// branch is a child of a block and the attesting stake in its subtree
+type branch struct {
+	hash   string
+	weight float64
+}
+
+// attest stores the chains of all validators and records their attestations,
+// which attacks may change
+func (g *lmdGhost) attest(sim *Simulation) {
+	for _, validator := range sim.validators {
+		g.tree.addChain(validator.Blockchain)
+	}
+	for _, validator := range sim.validators {
+		head := validator.Blockchain[len(validator.Blockchain)-1]
+		delete(g.latest, validator.Address)
+		vote := sim.attack.OnAttest(sim, validator, head)
+		if vote.Hash == "" {
+			continue
+		}
+		g.tree.add(vote)
+		g.latest[validator.Address] = vote.Hash
+	}
+}
+
+// weights adds up the stake of the latest attestations in the subtree of
+// every block, by hash
+func (g *lmdGhost) weights(sim *Simulation) map[string]float64 {
+	weights := make(map[string]float64)
+	for _, validator := range sim.validators {
+		for hash := g.latest[validator.Address]; hash != ""; hash = g.tree.parent(hash) {
+			weights[hash] += validator.Stake
+		}
+	}
+	return weights
+}
+
+// root returns the block the walk starts from
+func (g *lmdGhost) root(sim *Simulation) string {
+	if sim.finality != nil {
+		if _, ok := g.tree.blocks[sim.finality.lastJustified.hash]; ok {
+			return sim.finality.lastJustified.hash
+		}
+	}
+	return ""
+}
+
+// branches lists the children of the block with hash and their weights
+func (g *lmdGhost) branches(hash string, weights map[string]float64) []branch {
+	children := g.tree.children[hash]
+	branches := make([]branch, len(children))
+	for i, child := range children {
+		branches[i] = branch{hash: child, weight: weights[child]}
+	}
+	return branches
+}
+
+// heaviest returns the branch with the most weight, breaking ties by the
+// higher hash
+func heaviest(branches []branch) branch {
+	best := branches[0]
+	for _, b := range branches[1:] {
+		if b.weight > best.weight || b.weight == best.weight && b.hash > best.hash {
+			best = b
+		}
+	}
+	return best
+}
+
+// head walks from hash to the block the rule picks, leaving out children no
+// attestation is for, such as blocks of forks consensus dropped
+func (g *lmdGhost) head(hash string, weights map[string]float64) string {
+	for {
+		branches := make([]branch, 0)
+		for _, b := range g.branches(hash, weights) {
+			if b.weight > 0 {
+				branches = append(branches, b)
+			}
+		}
+		if len(branches) == 0 {
+			return hash
+		}
+		hash = heaviest(branches).hash
+	}
+}
+
+// split returns the competing branches at the first block on the way to the
+// head where validators' chains part, or nil if they do not fork
+func (g *lmdGhost) split(sim *Simulation) []branch {
+	held := make(map[string]bool)
+	for _, validator := range sim.validators {
+		for _, block := range validator.Blockchain {
+			held[block.Hash] = true
+		}
+	}
+	weights := g.weights(sim)
+	hash := g.root(sim)
+	for {
+		branches := make([]branch, 0)
+		for _, b := range g.branches(hash, weights) {
+			if held[b.hash] || b.weight > 0 {
+				branches = append(branches, b)
+			}
+		}
+		switch {
+		case len(branches) == 0:
+			return nil
+		case len(branches) > 1:
+			return branches
+		}
+		hash = branches[0].hash
+	}
+}
+
+// tip returns the head of the longest chain holding the block with hash, and
+// whether any validator holds it
+func (g *lmdGhost) tip(sim *Simulation, hash string) (Block, bool) {
+	var tip Block
+	longest := 0
+	for _, validator := range sim.validators {
+		if len(validator.Blockchain) > longest && hasBlock(validator.Blockchain, hash) {
+			tip = validator.Blockchain[len(validator.Blockchain)-1]
+			longest = len(validator.Blockchain)
+		}
+	}
+	return tip, longest > 0
+}
+
+// ForkChoice returns the validator with the longest chain holding the head
+// the rule picks, or nil to delay consensus if no validator holds it
+func (g *lmdGhost) ForkChoice(sim *Simulation) *Validator {
+	for _, validator := range sim.validators {
+		g.tree.addChain(validator.Blockchain)
+	}
+	head := g.head(g.root(sim), g.weights(sim))
+	var headValidator *Validator
+	for _, validator := range sim.validators {
+		if (headValidator == nil || len(validator.Blockchain) > len(headValidator.Blockchain)) && hasBlock(validator.Blockchain, head) {
+			headValidator = validator
+		}
+	}
+	if headValidator != nil {
+		fmt.Fprintf(sim.out, "LMD-GHOST head is block %d\n", g.tree.blocks[head].Index)
+	}
+	return headValidator
+}
@@ -0,0 +1,59 @@
+package pos
+
+import "testing"
+
+func TestGhostHeadFollowsTheHeaviestSubtree(t *testing.T) {
+	// g <- a <- a2
+	//   <- b
+	//   <- c, dropped by consensus
+	g := newLMDGhost()
+	genesis := Block{Hash: "g"}
+	g.tree.addChain([]Block{genesis, {Hash: "a", PrevHash: "g"}, {Hash: "a2", PrevHash: "a"}})
+	g.tree.addChain([]Block{genesis, {Hash: "b", PrevHash: "g"}})
+	g.tree.addChain([]Block{genesis, {Hash: "c", PrevHash: "g"}})
+
+	tests := []struct {
+		name    string
+		weights map[string]float64
+		want    string
+	}{
+		{"heavier subtree", map[string]float64{"g": 5, "a": 2, "a2": 2, "b": 3}, "b"},
+		{"deepest block of the heavier subtree", map[string]float64{"g": 5, "a": 4, "a2": 2, "b": 1}, "a2"},
+		{"tie broken by the higher hash", map[string]float64{"g": 6, "a": 3, "a2": 3, "b": 3}, "b"},
+		{"children without attestations left out", map[string]float64{"g": 2, "a": 2}, "a"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := g.head("", test.weights); got != test.want {
+				t.Errorf("head = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGhostTipOfABranchNobodyHolds(t *testing.T) {
+	s := testScenario(1)
+	s.ForkChoice = "lmd_ghost"
+	sim := newTestSimulation(t, s)
+	if err := sim.createActors(s); err != nil {
+		t.Fatalf("createActors: %v", err)
+	}
+	if _, ok := sim.ghost.tip(sim, "missing"); ok {
+		t.Error("tip found a branch no validator holds")
+	}
+	genesis := sim.validators[0].Blockchain[0]
+	if tip, ok := sim.ghost.tip(sim, genesis.Hash); !ok || tip.Hash != genesis.Hash {
+		t.Errorf("tip of the genesis block = %v, %t", tip.Hash, ok)
+	}
+}
+
+func TestBalanceDelaysConsensusUnderGhost(t *testing.T) {
+	s := testScenario(1)
+	s.NumSlots = 60
+	s.Attack = "balance"
+	s.ForkChoice = "lmd_ghost"
+	report := simulate(t, s).Evaluation.Security
+	if report.ForkChoice != "lmd_ghost" || report.DelayedConsensusRounds == 0 {
+		t.Errorf("attack had no effect: %+v", report)
+	}
+}
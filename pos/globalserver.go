@@ -1,278 +1,359 @@
 package pos
 
 import (
-	"bufio"
+	"context"
 	"fmt"
 	"io"
-	"log"
 	"math"
 	"math/rand"
 	"net"
 	"os"
 	"sort"
-	"strings"
 	"sync"
 	"time"
 
-	"github.com/joho/godotenv"
-	"golang.org/x/exp/slices"
 	"gonum.org/v1/gonum/stat/sampleuv"
 )
 
-// Blockchain is a series of validated Blocks
-var CertifiedBlockchain []Block
-var balanceAttackFork []Block
+// Simulation is one independent network running a scenario: its chain, its
+// validators and users, its clock and its randomness. Simulations share no
+// state, so any number of them can run at once in the same process.
+type Simulation struct {
+	// Blockchain is a series of validated Blocks
+	CertifiedBlockchain []Block
 
-//Temporary blocks if we want to look into finality attacks
-// var tempChain []Block
+	//Temporary blocks if we want to look into finality attacks
+	// tempChain []Block
 
-// Slice of validator pointers
-var validators = make([]*Validator, 0)
+	// Slice of validator pointers
+	validators []*Validator
 
-// Slice of delegate pointers
-var delegates = make([]*Validator, 0)
+	// Slice of delegate pointers
+	delegates []*Validator
 
-// Slice of user pointers who can make transactions
-var users = make(map[string]*User)
+	// Slice of user pointers who can make transactions
+	users map[string]*User
 
-// Current block proposer
-var proposer *Validator = nil
+	// Current block proposer
+	proposer *Validator
 
-// Validators that will validate the proposed block
-var validationCommittee = make([]*Validator, 0)
+	// Validators that will validate the proposed block
+	validationCommittee []*Validator
 
-// Malicious validators
-var malValidators = make([]*Validator, 0)
+	// Malicious validators
+	malValidators []*Validator
 
-var validatorsSliceLock = &sync.Mutex{}
+	validatorsSliceLock sync.Mutex
 
-var committeeSize = 0
+	committeeSize int
 
-var delegateSize = 0
+	delegateSize int
 
-var runConsensusCounter = 0
+	runConsensusCounter int
 
-var currAttack = ""
+	// Attacks played against the network, see Attack
+	attack Attack
 
-var forked bool
+	// Set while an attack keeps the chain forked, until longest chain
+	// consensus punishes forkProposer for it
+	forked bool
 
-var forkedCounter = 0
+	forkProposer *Validator
 
-var ForkedBlockchain = make([][]*Validator, 2)
+	delegateCounter int
 
-var forkProposer *Validator = nil
+	roundCount int
 
-var delegateCounter = 0
+	startTime time.Time
 
-var roundCount = 0
+	// Source of time for slots, transactions and block timestamps
+	clock Clock
 
-var startTime = time.Now()
+	// Single source of randomness for the run. Committees, proposers, stakes,
+	// addresses, keys and transactions are all drawn from it, so a run is
+	// reproducible from its seed.
+	rng *rand.Rand
 
-var blockchainType string
+	// Scenario the network is running
+	scenario Scenario
 
-func Run(runType string, numValidators int, numUsers int, numMal int, comSize int, delSize int, blkChainType string, attack string) {
-	err := godotenv.Load()
+	blockchainType string
+
+	// Rules of the blockchain type, see ConsensusProtocol
+	protocol ConsensusProtocol
+
+	// Finality gadget on top of longest chain consensus, nil without one
+	finality *casperFFG
+
+	// Fork choice rule replacing the longest chain, nil for the longest chain
+	ghost *lmdGhost
+
+	// Seed validators evaluate their VRF on under vrf sortition, moved on
+	// every round
+	sortitionSeed []byte
+
+	// Event loop driving time slots, validators and users
+	engine *eventEngine
+
+	// Where the server reports what happens in the network
+	out io.Writer
+
+	// Metrics of the time slot in progress, written to metricsWriter when it ends
+	currentSlot SlotMetrics
+
+	metricsWriter MetricsWriter
+
+	// First error writing metrics, after which no more are written
+	metricsErr error
+
+	// Attack outcomes gathered while the run progresses
+	security SecurityReport
+
+	// Consecutive slots forked and consensus rounds delayed so far
+	forkedStreak, delayStreak int
+	// Consecutive slots validators' chains had several heads so far
+	splitStreak int
+
+	// Slots every transaction users sent was sent after, by ID, and the
+	// transactions a malicious proposer left out of its block. Both outlive
+	// the transaction's inclusion, as consensus may drop its block.
+	sentAt   map[int]int
+	censored map[int]bool
+	// Transactions whose effects accepted blocks applied, by ID
+	applied map[int]appliedTransaction
+
+	// Connections of validators and users that joined over TCP, nil once the
+	// run is stopping
+	conns     map[net.Conn]bool
+	connsLock sync.Mutex
+	handlers  sync.WaitGroup
+
+	transactionID     int
+	userID            int
+	usersSliceLock    sync.Mutex
+	userIDLock        sync.Mutex
+	transactionIDLock sync.Mutex
+}
+
+// Results is the outcome of a finished run
+type Results struct {
+	Scenario   Scenario   `json:"scenario"`
+	Evaluation Evaluation `json:"evaluation"`
+	Slots      int        `json:"slots"`
+	// slots, blocks, duration or cancelled
+	StopReason string `json:"stopReason"`
+}
+
+// Simulate runs a headless simulation of s until it reaches one of its bounds
+// or ctx is cancelled, reporting to standard output. Metrics of every slot are
+// written to metrics unless it is nil.
+func Simulate(ctx context.Context, s Scenario, metrics MetricsWriter) (Results, error) {
+	sim, err := NewSimulation(s, metrics, os.Stdout)
 	if err != nil {
-		log.Fatal(err)
+		return Results{}, err
 	}
-	startTime = time.Now()
-	committeeSize = comSize
-	delegateSize = delSize
-	delegateCounter = 2 * delegateSize
-	blockchainType = blkChainType
+	return sim.Simulate(ctx)
+}
 
-	currAttack = attack
-	for i := range ForkedBlockchain {
-		ForkedBlockchain[i] = make([]*Validator, numValidators/2)
+// NewSimulation validates s, seeds the run's randomness, creates the genesis
+// block and schedules the time slots. The simulation reports what happens to
+// out and writes the metrics of every slot to metrics unless it is nil.
+func NewSimulation(s Scenario, metrics MetricsWriter, out io.Writer) (*Simulation, error) {
+	err := s.Validate()
+	if err != nil {
+		return nil, err
+	}
+	if s.Seed == 0 {
+		s.Seed = time.Now().UnixNano()
+	}
+	fmt.Fprintf(out, "seed: %d\n", s.Seed)
+
+	protocol, err := newConsensusProtocol(s.BlockchainType)
+	if err != nil {
+		return nil, err
+	}
+	attack, err := newAttack(s.Attack)
+	if err != nil {
+		return nil, err
+	}
+
+	clock := s.newClock()
+	sim := &Simulation{
+		validators:          make([]*Validator, 0),
+		delegates:           make([]*Validator, 0),
+		users:               make(map[string]*User),
+		validationCommittee: make([]*Validator, 0),
+		malValidators:       make([]*Validator, 0),
+		committeeSize:       s.CommitteeSize,
+		delegateSize:        s.DelegateSize,
+		delegateCounter:     2 * s.DelegateSize,
+		attack:              attack,
+		startTime:           clock.Now(),
+		clock:               clock,
+		rng:                 rand.New(rand.NewSource(s.Seed)),
+		scenario:            s,
+		blockchainType:      s.BlockchainType,
+		protocol:            protocol,
+		engine:              newEventEngine(clock),
+		out:                 out,
+		metricsWriter:       metrics,
+		security:            newSecurityReport(s),
+		conns:               make(map[net.Conn]bool),
+		applied:             make(map[int]appliedTransaction),
+		sentAt:              make(map[int]int),
+		censored:            make(map[int]bool),
 	}
 
 	// create genesis block
-	t := time.Now()
+	t := sim.clock.Now()
 	genesisBlock := Block{}
 	genesisBlock = Block{Index: 0, Timestamp: t.String(), Transactions: []Transaction{}, Hash: calculateBlockHash(genesisBlock), PrevHash: "", Validator: ""}
-	CertifiedBlockchain = append(CertifiedBlockchain, genesisBlock)
-
-	if attack == "balance" {
-		// create initial fork
-		t := time.Now()
-		genesisBlockFork := Block{}
-		genesisBlockFork = Block{Index: 1, Timestamp: t.String(), Transactions: []Transaction{}, Hash: calculateBlockHash(genesisBlockFork), PrevHash: "", Validator: ""}
-		balanceAttackFork = append(balanceAttackFork, genesisBlockFork)
+	sim.CertifiedBlockchain = append(sim.CertifiedBlockchain, genesisBlock)
+	sim.sortitionSeed = []byte(genesisBlock.Hash)
+	if s.Finality == "casper_ffg" {
+		sim.finality = newCasperFFG(s.EpochLength, genesisBlock)
 	}
-
-	tcpPort := os.Getenv("PORT")
-
-	// start TCP and serve TCP server
-	server, err := net.Listen("tcp", ":"+tcpPort)
-	if err != nil {
-		log.Fatal(err)
+	if s.ForkChoice == "lmd_ghost" {
+		sim.ghost = newLMDGhost()
 	}
-	log.Println("TCP Server Listening on port :", tcpPort)
-	defer server.Close()
+
+	sim.attack.Setup(sim)
 
 	//Advances time slots, choosing new proposers that add blocks to the chain and new validation committees
-	//Standard proof of stake
-	if blockchainType == "pos" || blockchainType == "slashing" {
-		if attack == "balance" {
-			go func() {
-				for {
-					balanceNextTimeSlot()
-					roundCount++
-					if roundCount%10 == 0 {
-						printEvaluation()
-					}
-				}
-			}()
-		} else {
-			go func() {
-				for {
-					nextTimeSlot()
-					roundCount++
-					if roundCount%10 == 0 {
-						printEvaluation()
-					}
-				}
-			}()
-		}
-	} else if blockchainType == "reputation" {
-		if attack == "balance" {
-			go func() {
-				for {
-					balanceReputationNextTimeSlot()
-					roundCount++
-					if roundCount%10 == 0 {
-						printEvaluation()
-					}
-				}
-			}()
-		} else {
-			go func() {
-				for {
-					nextReputationTimeSlot()
-					roundCount++
-					if roundCount%10 == 0 {
-						printEvaluation()
-					}
-				}
-			}()
-		}
-	} else {
-		fmt.Println("Invalid blockchain type")
-		return
+	sim.scheduleTimeSlots(sim.nextTimeSlot)
+	if s.Duration > 0 {
+		sim.engine.deadline = sim.startTime.Add(time.Duration(s.Duration))
 	}
+	return sim, nil
+}
 
-	//auto create connections
-	go func() {
-		if runType == "manual" {
-			return
-		}
-
-		if attack == "balance" {
-			createBalanceAttackConnections(numValidators, numMal, runType, numUsers)
-		} else {
-			for numValidators > 0 {
-				conn, err := net.Dial("tcp", ":9000")
-				if err != nil {
-					fmt.Println("Error connecting:", err)
-					return
-				}
-				malString := "n"
-				if numMal > 0 {
-					malString = "y"
-					numMal--
-				}
-				go handleConnection(conn, runType, "v", malString, false)
-				numValidators--
-			}
-			for numUsers > 0 {
-				conn, err := net.Dial("tcp", ":9000")
-				if err != nil {
-					fmt.Println("Error connecting:", err)
-					return
-				}
-				if err != nil {
-					log.Fatal(err)
-				}
-				go handleConnection(conn, runType, "u", "", false)
-				numUsers--
-			}
-		}
-	}()
-	//Accepts connections joining the network
-	for {
-		conn, err := server.Accept()
-		if err != nil {
-			log.Fatal(err)
-		}
-		go handleConnection(conn, runType, "", "", false)
+// Simulate runs the simulation headless until it reaches one of its bounds or
+// ctx is cancelled. Validators and users live in-process and are driven by
+// the event engine, so no TCP connections are opened. With a virtual clock the
+// slots run back to back, and runs with the same seed are identical.
+func (sim *Simulation) Simulate(ctx context.Context) (Results, error) {
+	err := sim.createActors(sim.scenario)
+	if err != nil {
+		return Results{}, err
 	}
 
+	sim.engine.run(ctx, func() bool {
+		return sim.stopReason() != ""
+	})
+	return sim.finish(ctx)
 }
 
-func createBalanceAttackConnections(numValidators int, numMal int, runType string, numUsers int) {
-	// split views of validators if balance attack
-	viewForkedChain := false
-	numHonestValidators := numValidators - numMal
-	honestValidatorsSplit := 0
-	malValidatorsSplit := 0
-	originalNumMal := numMal
+// stopReason names the bound the run has reached, or is empty while it
+// should go on
+func (sim *Simulation) stopReason() string {
+	s := sim.scenario
+	switch {
+	case s.NumSlots > 0 && sim.roundCount >= s.NumSlots:
+		return "slots"
+	case s.MaxBlocks > 0 && len(sim.CertifiedBlockchain) >= s.MaxBlocks:
+		return "blocks"
+	case s.Duration > 0 && !sim.clock.Now().Before(sim.startTime.Add(time.Duration(s.Duration))):
+		return "duration"
+	}
+	return ""
+}
 
-	for numValidators > 0 {
+// finish prints the final evaluation, flushes the metrics and reports why
+// the run stopped
+func (sim *Simulation) finish(ctx context.Context) (Results, error) {
+	sim.printEvaluation()
+	reason := sim.stopReason()
+	if reason == "" && ctx.Err() != nil {
+		reason = "cancelled"
+	}
+	fmt.Fprintf(sim.out, "Stopped: %s\n", reason)
+	results := Results{
+		Scenario:   sim.scenario,
+		Evaluation: sim.evaluate(),
+		Slots:      sim.roundCount,
+		StopReason: reason,
+	}
+	return results, sim.flushMetrics()
+}
 
-		// make only half of the validators see one side of fork for balance attack
-		if numMal == 0 && honestValidatorsSplit <= numHonestValidators/2 {
-			viewForkedChain = true
-		} else if numMal > 0 && malValidatorsSplit < originalNumMal/2 {
-			viewForkedChain = true
-		} else {
-			viewForkedChain = false
-		}
+// scheduleTimeSlots fires timeSlot once every scenario.SlotDuration
+func (sim *Simulation) scheduleTimeSlots(timeSlot func()) {
+	slotDuration := time.Duration(sim.scenario.SlotDuration)
+	slotTime := sim.clock.Now().Add(slotDuration)
+	var next func()
+	next = func() {
+		sim.currentSlot = SlotMetrics{Slot: sim.roundCount + 1}
+		timeSlot()
+		sim.roundCount++
+		sim.recordSlotSecurity()
+		sim.writeSlotMetrics()
+		//the last slot leaves the results to finish
+		if sim.roundCount%10 == 0 && sim.stopReason() == "" {
+			sim.printEvaluation()
+		}
+		slotTime = slotTime.Add(slotDuration)
+		sim.engine.schedule(slotTime, next)
+	}
+	sim.engine.schedule(slotTime, next)
+}
 
-		conn, err := net.Dial("tcp", ":9000")
-		if err != nil {
-			fmt.Println("Error connecting:", err)
-			return
-		}
-		malString := "n"
+// scheduleTransactions makes user send a random transaction every
+// scenario.TransactionInterval
+func (sim *Simulation) scheduleTransactions(user *User) {
+	transactionInterval := time.Duration(sim.scenario.TransactionInterval)
+	transactionTime := sim.clock.Now()
+	var next func()
+	next = func() {
+		user.sendRandomTransaction()
+		transactionTime = transactionTime.Add(transactionInterval)
+		sim.engine.schedule(transactionTime, next)
+	}
+	sim.engine.schedule(transactionTime, next)
+}
+
+// createActors instantiates in-process validators and users that report their
+// activity nowhere
+func (sim *Simulation) createActors(s Scenario) error {
+	numValidators := s.NumValidators
+	numMal := s.NumMal
+	for numValidators > 0 {
+		isMal := false
 		if numMal > 0 {
-			malString = "y"
+			isMal = true
 			numMal--
-			if viewForkedChain {
-				malValidatorsSplit++
-			}
-		} else {
-			honestValidatorsSplit++
 		}
-
-		go handleConnection(conn, runType, "v", malString, viewForkedChain)
+		sim.newValidator(io.Discard, sim.scenario.StakeDistribution.sample(sim.rng), isMal)
 		numValidators--
 	}
+	return sim.createUsers(s.NumUsers)
+}
+
+func (sim *Simulation) createUsers(numUsers int) error {
 	for numUsers > 0 {
-		conn, err := net.Dial("tcp", ":9000")
+		user, err := sim.newAutoUser(io.Discard)
 		if err != nil {
-			fmt.Println("Error connecting:", err)
-			return
+			return err
 		}
-		if err != nil {
-			log.Fatal(err)
-		}
-		go handleConnection(conn, runType, "u", "", viewForkedChain)
+		sim.scheduleTransactions(user)
 		numUsers--
 	}
+	return nil
 }
 
-func chooseValidationCommittee(validators []*Validator, committeeSize int) []*Validator {
+func (sim *Simulation) chooseValidationCommittee(validators []*Validator, committeeSize int) []*Validator {
 	//make a slice of stakes for weighted dsitribution
-	validatorsSliceLock.Lock()
+	sim.validatorsSliceLock.Lock()
 	stakeWeights := make([]float64, len(validators))
 	for i, validator := range validators {
 		stakeWeights[i] = validator.Stake
 	}
-	validatorsSliceLock.Unlock()
+	sim.validatorsSliceLock.Unlock()
 
 	validationCommittee := make([]*Validator, 0)
-	weightedDist := sampleuv.NewWeighted(stakeWeights, nil)
-	for i := 0; i < committeeSize; i++ {
+	weightedDist := sampleuv.NewWeighted(stakeWeights, sampleSource{sim.rng})
+	//rounding can leave weight behind once every validator was taken, so stop
+	//drawing there instead of relying on Take to report it
+	for i := 0; i < committeeSize && i < len(validators); i++ {
 		index, isOk := weightedDist.Take()
 		if isOk {
 			validationCommittee = append(validationCommittee, validators[index])
@@ -283,64 +364,69 @@ func chooseValidationCommittee(validators []*Validator, committeeSize int) []*Va
 	return validationCommittee
 }
 
-func chooseDelegates(validators []*Validator, delegateSize int) []*Validator {
+func (sim *Simulation) chooseDelegates(validators []*Validator, delegateSize int) []*Validator {
 
 	validatorMap := make(map[string]*Validator)
 
 	//send delegate vote requests to all validators
-	validatorsSliceLock.Lock()
+	sim.validatorsSliceLock.Lock()
+	voteReplies := make([]DelegateVoteMessage, 0, len(validators))
 	for _, validator := range validators {
 		validatorMap[validator.Address] = validator
 		msg := DelegateVoteRequestMessage{
 			delegateSize: delegateSize,
 		}
-		validator.delegateVoteRequestChannel <- msg
+		voteReplies = append(voteReplies, validator.receiveDelegateVoteRequest(msg))
 	}
-	validatorsSliceLock.Unlock()
+	sim.validatorsSliceLock.Unlock()
 	//Recieve and tally up votes, punishing those who voted for someone with less reputation
 	delegateResultMap := make(map[string]int)
-	for _, validator := range validators {
-		msg := <-validator.delegateVoteChannel
+	for i, validator := range validators {
+		msg := voteReplies[i]
 		validator.reputation = math.Min(100, validator.reputation+1)
 		for _, validatorVoted := range msg.delegateVotes {
 			delegateResultMap[validatorVoted.Address] += 1
 		}
 	}
-	//select the winners
-	validatorAddresses := make([]string, len(delegateResultMap))
-	for va := range delegateResultMap {
-		validatorAddresses = append(validatorAddresses, va)
+	//select the winners, breaking ties by the order validators joined
+	validatorAddresses := make([]string, 0, len(delegateResultMap))
+	for _, validator := range validators {
+		if _, ok := delegateResultMap[validator.Address]; ok {
+			validatorAddresses = append(validatorAddresses, validator.Address)
+		}
 	}
 
-	sort.Slice(validatorAddresses, func(i, j int) bool {
+	sort.SliceStable(validatorAddresses, func(i, j int) bool {
 		return delegateResultMap[validatorAddresses[i]] > delegateResultMap[validatorAddresses[j]]
 	})
 
-	validatorAddresses = validatorAddresses[:delegateSize]
-	delegates = make([]*Validator, 0)
+	//fewer validators than delegates may have joined
+	if delegateSize < len(validatorAddresses) {
+		validatorAddresses = validatorAddresses[:delegateSize]
+	}
+	sim.delegates = make([]*Validator, 0)
 	for _, validatorAddress := range validatorAddresses {
-		delegates = append(delegates, validatorMap[validatorAddress])
+		sim.delegates = append(sim.delegates, validatorMap[validatorAddress])
 	}
-	return delegates
+	return sim.delegates
 
 }
 
-func chooseBlockProposer() *Validator {
-	if len(validationCommittee) == 0 {
+func (sim *Simulation) chooseBlockProposer(committee []*Validator) *Validator {
+	if len(committee) == 0 {
 		return nil
 	}
 
 	totalWeight := 0.0
-	for _, validator := range validationCommittee {
+	for _, validator := range committee {
 		totalWeight += validator.Stake
 	}
 
 	randomNumber := 0.0
-	rand.Seed(time.Now().UnixNano())
-	randomNumber = rand.Float64() * totalWeight
+	randomNumber = sim.rng.Float64() * totalWeight
 
 	weightSum := 0.0
-	for _, validator := range validationCommittee {
+	for _, validator := range committee {
 		weightSum += validator.Stake
 		if weightSum >= randomNumber {
 			return validator
@@ -349,96 +435,46 @@ func chooseBlockProposer() *Validator {
 	return nil
 }
 
-func balanceLongestChainConsensus() {
-	longestLength := -1
-	secondLongestLength := -1
-	var longestValidator *Validator = nil
-	for _, validator := range validators {
-		// + 1 to check for second longest chain for balance attack
-		if len(validator.Blockchain)+1 >= longestLength {
-			if longestLength == -1 && len(validator.Blockchain) > longestLength {
-				longestValidator = validator
-				longestLength = len(validator.Blockchain)
-				continue
-			}
-
-			longestValidatorLastBlock := longestValidator.Blockchain[len(longestValidator.Blockchain)-1]
-			curValidatorLastBlock := validator.Blockchain[len(validator.Blockchain)-1]
-
-			if longestValidatorLastBlock.Hash != curValidatorLastBlock.Hash {
-				if len(validator.Blockchain) > longestLength {
-					secondLongestLength = longestLength
-					longestValidator = validator
-					longestLength = len(validator.Blockchain)
-				} else {
-					secondLongestLength = len(validator.Blockchain)
-				}
-			}
-		}
-	}
-	if longestLength-secondLongestLength <= 1 {
-		fmt.Println("Longest chain consensus delayed")
+// longestChainConsensus has every validator adopt the chain picked by the
+// protocol's fork choice, or by LMD-GHOST, unless an attack delays it, and
+// punishes the proposer of the fork it settles. A finality gadget keeps the
+// chain on its highest justified checkpoint.
+func (sim *Simulation) longestChainConsensus() {
+	var chosen *Validator
+	if sim.ghost != nil {
+		chosen = sim.ghost.ForkChoice(sim)
 	} else {
-		CertifiedBlockchain = make([]Block, len(longestValidator.Blockchain))
-		copy(CertifiedBlockchain, longestValidator.Blockchain)
-
-		for _, validator := range validators {
-			//broadcast the verified transactions to all blocks
-			if validator.Address == longestValidator.Address {
-				continue
-			}
-			blockChainBuffer := make([]Block, len(CertifiedBlockchain))
-			copy(blockChainBuffer, CertifiedBlockchain)
-			longestValidator.transactionPoolLock.Lock()
-			unconfirmedTransactionsBuffer := make(map[int]Transaction)
-			for id, transaction := range longestValidator.unconfirmedTransactions {
-				unconfirmedTransactionsBuffer[id] = transaction
-			}
-			confirmedTransactionsBuffer := make(map[int]bool)
-			for id, status := range longestValidator.confirmedTransactions {
-				confirmedTransactionsBuffer[id] = status
-			}
-			longestValidator.transactionPoolLock.Unlock()
-			validator.Blockchain = blockChainBuffer
-			validator.unconfirmedTransactions = unconfirmedTransactionsBuffer
-			validator.confirmedTransactions = confirmedTransactionsBuffer
-		}
-		//slash fork proposer if there was a fork
-		if forked {
-			fmt.Printf("SLASHED FORK PROPOSER")
-			if blockchainType == "slashing" {
-				forkProposer.Stake *= 0.2
-			}
-			if blockchainType == "reputation" {
-				forkProposer.reputation *= 0.2
-			}
-			forkProposer = nil
-		}
-
-		forked = false
+		chosen = sim.protocol.ForkChoice(sim)
 	}
-}
-
-func longestChainConsensus() {
-	longestLength := -1
-	var longestValidator *Validator = nil
-	for _, validator := range validators {
-		if len(validator.Blockchain) > longestLength {
-			longestValidator = validator
-			longestLength = len(validator.Blockchain)
-		}
+	var longestValidator *Validator
+	if chosen != nil {
+		longestValidator = sim.attack.OnConsensus(sim, chosen)
+	}
+	if longestValidator != nil && sim.finality != nil {
+		longestValidator = sim.finality.forkChoice(sim, longestValidator)
+	}
+	if longestValidator == nil {
+		fmt.Fprintln(sim.out, "Longest chain consensus delayed")
+		sim.recordConsensus(true)
+		return
 	}
 
-	CertifiedBlockchain = make([]Block, len(longestValidator.Blockchain))
-	copy(CertifiedBlockchain, longestValidator.Blockchain)
+	sim.recordConsensus(false)
+	sim.CertifiedBlockchain = make([]Block, len(longestValidator.Blockchain))
+	copy(sim.CertifiedBlockchain, longestValidator.Blockchain)
+	sim.revertOrphanedTransactions()
 
-	for _, validator := range validators {
+	deepestReorg := 0
+	for _, validator := range sim.validators {
 		//broadcast the verified transactions to all blocks
 		if validator.Address == longestValidator.Address {
 			continue
 		}
-		blockChainBuffer := make([]Block, len(CertifiedBlockchain))
-		copy(blockChainBuffer, CertifiedBlockchain)
+		if depth := reorgDepth(validator.Blockchain, sim.CertifiedBlockchain); depth > deepestReorg {
+			deepestReorg = depth
+		}
+		blockChainBuffer := make([]Block, len(sim.CertifiedBlockchain))
+		copy(blockChainBuffer, sim.CertifiedBlockchain)
 
 		longestValidator.transactionPoolLock.Lock()
 		unconfirmedTransactionsBuffer := make(map[int]Transaction)
@@ -456,1081 +492,378 @@ func longestChainConsensus() {
 		validator.unconfirmedTransactions = unconfirmedTransactionsBuffer
 		validator.confirmedTransactions = confirmedTransactionsBuffer
 	}
+	sim.recordReorg(deepestReorg)
 
 	//slash fork proposer if there was a fork
-	if forked {
-		if blockchainType == "pos" || blockchainType == "slashing" {
-			fmt.Printf("SLASHED FORK PROPOSER")
-			if blockchainType == "slashing" {
-				forkProposer.Stake *= 0.2
-			}
-			forkProposer = nil
-		} else if blockchainType == "reputation" {
-			fmt.Printf("SLASHED FORK PROPOSER")
-			forkProposer.reputation *= 0.2
-			forkProposer = nil
-		}
-
+	if sim.forked {
+		fmt.Fprintf(sim.out, "SLASHED FORK PROPOSER")
+		sim.protocol.PunishForkProposer(sim, sim.forkProposer)
+		sim.forkProposer = nil
 	}
 
-	forked = false
+	sim.forked = false
 }
-func balancePrintInfo() {
-	printString := ""
-	for _, block := range CertifiedBlockchain {
-		printString += "->["
-		for _, transaction := range block.Transactions {
-			printString += fmt.Sprintf("%d,", transaction.ID)
-		}
-		printString = printString[:len(printString)-1]
-		printString += "]"
-	}
 
-	printString = printString[1:]
-	println("BLOCKCHAIN")
-	println(printString)
-
-	//prints User balances
-	// println("User balances")
-	// for user := range users {
-	// 	fmt.Printf("%s: %f\n", users[user].Name, users[user].Balance)
-	// }
-
-	//prints Validator balances
-	println("Validator balances")
-	for _, validator := range validators {
-		fmt.Printf("%s: %f, %d, Evil: %t \n", validator.Address[:3], validator.Stake, validator.committeeCount, validator.IsMalicious)
-		printString := ""
-		for _, block := range validator.Blockchain {
-			printString += "->["
-			for _, transaction := range block.Transactions {
-				printString += fmt.Sprintf("%d,", transaction.ID)
-			}
-			printString = printString[:len(printString)-1]
-			printString += "]"
-		}
-		printString = printString[1:]
-		fmt.Printf("VALIDATOR %s BLOCKCHAIN\n", validator.Address[:3])
-		println(printString)
+// nextTimeSlot runs one time slot: the protocol chooses a committee and a
+// proposer, the committee votes on the proposer's block and the protocol
+// settles the outcome. The attacks' hooks run at every step.
+func (sim *Simulation) nextTimeSlot() {
+	if len(sim.validators) == 0 {
+		return
 	}
-}
 
-func printInfo() {
-	// println("Delegates")
-	// for _, delegate := range delegates {
-	// 	println(delegate.Address[:3])
-	// }
+	fmt.Fprintf(sim.out, "\nTime slot %s\n\n", sim.clock.Now().Format("15:04:05"))
 
-	printString := ""
-	for _, block := range CertifiedBlockchain {
-		printString += "->["
-		for _, transaction := range block.Transactions {
-			printString += fmt.Sprintf("%d,", transaction.ID)
-		}
-		printString = printString[:len(printString)-1]
-		printString += "]"
+	//a new honest validator bootstraps from its peers
+	if sim.scenario.JoinInterval > 0 && sim.roundCount > 0 && sim.roundCount%sim.scenario.JoinInterval == 0 {
+		sim.newValidator(io.Discard, sim.scenario.StakeDistribution.sample(sim.rng), false)
 	}
 
-	printString = printString[1:]
-	println("BLOCKCHAIN")
-	println(printString)
-
-	//prints User balances
-	// println("User balances")
-	// for user := range users {
-	// 	fmt.Printf("%s: %f\n", users[user].Name, users[user].Balance)
-	// }
-
-	//prints Validator balances
-	println("Validator balances")
-	for _, validator := range validators {
-		fmt.Printf("%s: %f, %f\n", validator.Address[:3], validator.Stake, validator.reputation)
-		// printString := ""
-		// for _, block := range validator.Blockchain {
-		// 	printString += "->["
-		// 	for _, transaction := range block.Transactions {
-		// 		printString += fmt.Sprintf("%d,", transaction.ID)
-		// 	}
-		// 	printString = printString[:len(printString)-1]
-		// 	printString += "]"
-		// }
-		// printString = printString[1:]
-		// fmt.Printf("VALIDATOR %s BLOCKCHAIN\n", validator.Address[:3])
-		// println(printString)
-	}
-
-	//prints Forked group
-	// if currAttack == "network_partition" {
-	// 	println("Fork Groups")
-	// 	for i := 0; i < len(ForkedBlockchain); i++ {
-	// 		for j := 0; j < len(ForkedBlockchain[i]); j++ {
-	// 			if ForkedBlockchain[i][j] != nil {
-	// 				var validator = *ForkedBlockchain[i][j]
-	// 				fmt.Printf("%s ", validator.Address[:3])
-	// 			} else {
-	// 				fmt.Printf("nil ")
-	// 			}
-	// 		}
-	// 		fmt.Println()
-	// 	}
-	// }
-}
+	sim.runConsensusCounter += 1
 
-func balanceNextTimeSlot() {
-	time.Sleep(5 * time.Second)
-	fmt.Printf("\nTime slot %s\n\n", time.Now().Format("15:04:05"))
-	runConsensusCounter += 1
-
-	if runConsensusCounter >= 5 {
-		balanceLongestChainConsensus()
-		runConsensusCounter = 0
+	//attest to the checkpoints at the end of every epoch
+	if sim.finality != nil && sim.currentSlot.Slot%sim.scenario.EpochLength == 0 {
+		sim.finality.attest(sim)
+	}
+	if sim.ghost != nil {
+		sim.ghost.attest(sim)
 	}
 
-	//randomly choose new committee of a third of all validators who will validate the new block
-	validationCommittee = chooseValidationCommittee(validators, committeeSize)
-	fmt.Println("New validation committee chosen")
-	for _, commit := range validationCommittee {
-		commit.committeeCount += 1
-		// fmt.Println(commit.Address[:3])
+	if sim.runConsensusCounter >= sim.scenario.ConsensusInterval {
+		sim.longestChainConsensus()
+		sim.runConsensusCounter = 0
 	}
-	//Choose a new block proposer based on stake
-	proposer = chooseBlockProposer()
-	if proposer == nil {
+
+	sim.validationCommittee = sim.protocol.SelectCommittee(sim)
+	sim.currentSlot.Committee = addresses(sim.validationCommittee)
+	sim.proposer = sim.protocol.SelectProposer(sim, sim.validationCommittee)
+	if sim.proposer == nil {
 		return
 	}
-	proposer.proposerCount += 1
-	fmt.Printf("Proposer %s chosen as new block proposer\n", proposer.Address[:3])
+	sim.proposer.proposerCount += 1
+	sim.currentSlot.Proposer = sim.proposer.Address
+	fmt.Fprintf(sim.out, "Proposer %s chosen as new block proposer\n", sim.proposer.Address[:3])
+	slot := &Slot{Proposer: sim.proposer, Committee: sim.validationCommittee}
+	sim.attack.OnProposerSelected(sim, slot)
 
 	//block proposer chooses a new block
-	newBlock, err := generateBlock(proposer)
+	newBlock, err := sim.protocol.ProposeBlock(sim, sim.proposer)
 	if err != nil {
-		fmt.Println(err.Error())
+		fmt.Fprintln(sim.out, err.Error())
 		return
 	}
-
-	// find length of the shorter fork
-	validatorsSliceLock.Lock()
-	shorterForkLength := math.MaxInt32
-	fmt.Println("Printing validator blockchains")
-	for _, validator := range validators {
-		if len(validator.Blockchain) < shorterForkLength {
-			shorterForkLength = len(validator.Blockchain)
-		}
+	//tendermint may propose the block of an earlier round again, which stays
+	//the block of the validator that built it and earns it the rewards
+	blockProposer := sim.proposer
+	if validator := sim.validatorByAddress(newBlock.Validator); validator != nil {
+		blockProposer = validator
 	}
-	validatorsSliceLock.Unlock()
-
-	//let malicious validators know if they should vote for/against block to balance
-	malVote := false
-	if len(proposer.Blockchain) == shorterForkLength {
-		malVote = true
+	slot.Proposals = []*Proposal{{Block: newBlock, Proposer: blockProposer}}
+	err = sim.attack.OnBlockGenerated(sim, slot)
+	if err != nil {
+		fmt.Fprintln(sim.out, err.Error())
+		return
 	}
 
-	fmt.Printf("Block %d chosen as new block\n", newBlock.Index)
+	fmt.Fprintf(sim.out, "Block %d chosen as new block\n", newBlock.Index)
 
 	//validation committee validates blocks
-	//broadcast block to all members of committee
-	for _, validator := range validationCommittee {
-		msg := ValidateBlockMessage{
-			newBlock: newBlock,
-			malVote:  malVote,
-		}
-		validator.incomingChannel <- msg
-	}
-
-	// Process validation results
-	validCount := 0
-	invalidCount := 0
-	validationResults := make(map[string]bool)
-	for _, validator := range validationCommittee {
-		msg := <-validator.outgoingChannel
-		switch msg := msg.(type) { // Use type assertion to determine the type of the received message
-		case ValidationStatusMessage:
-			validationResults[validator.Address] = msg.isValid
-			if msg.isValid == true {
-				validCount++
-			} else {
-				invalidCount++
-			}
-		default:
-			fmt.Printf("Received an unknown struct: %+v\n", msg)
-			fmt.Printf("%T\n", msg)
-		}
-	}
-
-	// fmt.Printf("Voting results\nInvalid Count: %d\nValid Count: %d\nCommittee size: %d\n", invalidCount, validCount, len(validationCommittee))
-
-	//add block if majority believe block is valid
-	isValid := validCount > len(validationCommittee)/2
-	if isValid {
-		// proposer.Blockchain = append(proposer.Blockchain, newBlock)
-		println("Valid block added to blockchain")
-		proposer.blockSuccessCount += 1
-
-		//broadcast the verified transactions to all blocks
-		msg := VerifiedBlockMessage{
-			transactions: newBlock.Transactions,
-			newBlock:     newBlock,
-		}
-		for _, validator := range validators {
-			validator.incomingChannel <- msg
-		}
-
-		//Update transactional amounts and reward proposer
-		for _, transaction := range newBlock.Transactions {
-			transaction.Sender.Balance -= (transaction.Amount + transaction.Reward)
-			transaction.Receiver.Balance += transaction.Amount
-			proposer.Stake += transaction.Reward
-
-			senderString := fmt.Sprintf("New balance: %f\n", transaction.Sender.Balance)
-			io.WriteString(transaction.Sender.conn, senderString)
-
-			receiverString := fmt.Sprintf("New balance: %f\n", transaction.Receiver.Balance)
-			io.WriteString(transaction.Receiver.conn, receiverString)
-		}
-	} else {
-		println("Committee votes block invalid")
-		if blockchainType == "slashing" {
-			proposer.Stake *= 0.2
-		}
-	}
-	//punish validators who voted against the majority
-	slashPercentage := 0.2
-	for _, validator := range validationCommittee {
-		if isValid {
-			if validationResults[validator.Address] == false {
-				if blockchainType == "slashing" {
-					validator.Stake *= slashPercentage
-				}
-			}
-		} else {
-			if validationResults[validator.Address] == true {
-				if blockchainType == "slashing" {
-					validator.Stake *= slashPercentage
-				}
-			}
-		}
+	var msg interface{} = ValidateBlockMessage{
+		newBlock: newBlock,
 	}
-
-	balancePrintInfo()
-}
-
-func printEvaluation() {
-	//print malicious nodes
-
-	println("\nRESULTS\n")
-	fmt.Printf("Total blocks: %d\n", len(CertifiedBlockchain))
-
-	malBlockCount := 0
-	transactionCount := 0
-	for _, block := range CertifiedBlockchain {
-		if block.IsMalicious {
-			malBlockCount++
+	if len(slot.Proposals) > 1 {
+		msg = ValidateShortAttackBlockMessage{
+			newBlock:    newBlock,
+			newBlockTwo: slot.Proposals[1].Block,
 		}
-		transactionCount += len(block.Transactions)
 	}
-	fmt.Printf("Malicious blocks: %d\n", malBlockCount)
-	fmt.Printf("Transactions validated: %d\n", transactionCount)
-	fmt.Printf("Time so far: %f\n", time.Now().Sub(startTime).Seconds())
-}
-
-func nextTimeSlot() {
-
-	//wait 5 seconds every slot
-	time.Sleep(5 * time.Second)
-
-	if len(validators) == 0 {
-		return
+	slot.Votes = sim.protocol.CollectVotes(sim, slot.Committee, msg)
+	sim.currentSlot.ValidVotes = slot.Votes.Valid
+	sim.currentSlot.InvalidVotes = slot.Votes.Invalid
+	sim.currentSlot.ValidTwoVotes = slot.Votes.ValidTwo
+	sim.currentSlot.InvalidTwoVotes = slot.Votes.InvalidTwo
+	if sim.scenario.SlashEquivocation && len(slot.Proposals) > 1 {
+		sim.slashEquivocators(slot)
 	}
 
-	fmt.Printf("\nTime slot %s\n\n", time.Now().Format("15:04:05"))
-
-	runConsensusCounter += 1
-
-	if runConsensusCounter >= 5 {
-		longestChainConsensus()
-		runConsensusCounter = 0
+	//add blocks if the committee accepts them
+	slot.Proposals[0].Accepted = slot.Votes.Accepted
+	if len(slot.Proposals) > 1 {
+		slot.Proposals[1].Accepted = slot.Votes.AcceptedTwo
 	}
+	sim.attack.OnVotesCounted(sim, slot)
 
-	//randomly choose new committee of a third of all validators who will validate the new block
-	validationCommittee = chooseValidationCommittee(validators, committeeSize)
-	fmt.Println("New validation committee chosen")
-	for _, commit := range validationCommittee {
-		commit.committeeCount += 1
-		// fmt.Println(commit.Address[:3])
+	//broadcast the verified transactions to all blocks
+	for _, proposal := range slot.Proposals {
+		proposal.Recipients = sim.validators
 	}
-	//Choose a new block proposer based on stake
-	proposer = chooseBlockProposer()
-	if proposer == nil {
-		return
+	sim.attack.OnBroadcast(sim, slot)
+	if multi, ok := sim.protocol.(multiLeaderProtocol); ok {
+		sim.addLeaderBlocks(slot, multi.OtherLeaders(sim))
 	}
-	proposer.proposerCount += 1
-	fmt.Printf("Proposer %s chosen as new block proposer\n", proposer.Address[:3])
 
-	//block proposer chooses a new block
-
-	//check what group proposer is in
-	proposerGroup := 1
-	if slices.Contains(ForkedBlockchain[0], proposer) {
-		proposerGroup = 0
+	for _, proposal := range slot.Proposals {
+		switch {
+		case proposal.Accepted:
+			sim.acceptBlock(proposal)
+		case !slot.Votes.Deferred:
+			fmt.Fprintln(sim.out, "Committee votes block invalid")
+			sim.protocol.PunishProposer(sim, proposal.Proposer)
+		}
 	}
-
-	// oldBlock := Blockchain[len(Blockchain)-1]
-	newBlock, err := generateBlock(proposer)
-	if err != nil {
-		fmt.Println(err.Error())
-		return
+	for _, proposal := range slot.Votes.Commits {
+		sim.acceptBlock(proposal)
 	}
-
-	evilProposer := false
-
-	if proposer.IsMalicious {
-		evilProposer = true
+	//an equivocating proposer leaves no single outcome to hold voters to
+	if len(slot.Proposals) == 1 {
+		sim.protocol.SettleVotes(sim, slot.Committee, slot.Votes, slot.Proposals[0].Accepted)
 	}
+	sim.printInfo()
+}
 
-	var newBlockTwo Block
-	if currAttack == "network_partition" && evilProposer {
-		println("EVIL PROPOSER DOING WORK")
-		newBlockTwo, err = generateBlock(proposer)
+// addLeaderBlocks adds the blocks of the other leaders of slot. Every block
+// reaches every validator, but unless an attack already decided who hears
+// what, each validator hears first from one of the leaders and keeps its
+// block, so leaders building on the same chain fork it.
+func (sim *Simulation) addLeaderBlocks(slot *Slot, leaders []*Validator) {
+	proposals := make([]*Proposal, 0, len(leaders))
+	for _, leader := range leaders {
+		block, err := sim.generateBlock(leader)
 		if err != nil {
-			fmt.Println(err.Error())
-			return
-		}
-	} else {
-		newBlockTwo = Block{}
-	}
-
-	fmt.Printf("Block %d chosen as new block\n", newBlock.Index)
-
-	//validation committee validates blocks
-	//broadcast block to all members of committee
-	for _, validator := range validationCommittee {
-		if currAttack == "network_partition" && evilProposer && !forked {
-			if evilProposer {
-				msg := ValidateShortAttackBlockMessage{
-					newBlock:    newBlock,
-					newBlockTwo: newBlockTwo,
-				}
-				validator.incomingChannel <- msg
-			}
-		} else {
-			msg := ValidateBlockMessage{
-				newBlock: newBlock,
-			}
-			validator.incomingChannel <- msg
-		}
-	}
-
-	// Process validation results
-	validCount := 0
-	invalidCount := 0
-	validTwoCount := 0
-	invalidTwoCount := 0
-	validationResults := make(map[string]bool)
-	// validationResultsTwo := make(map[string]bool)
-	for _, validator := range validationCommittee {
-		msg := <-validator.outgoingChannel
-		switch msg := msg.(type) { // Use type assertion to determine the type of the received message
-		case ValidationStatusMessage:
-			validationResults[validator.Address] = msg.isValid
-			if msg.isValid == true {
-				validCount++
-			} else {
-				invalidCount++
-			}
-		case ValidationShortAttackStatusMessage:
-			validationResults[validator.Address] = msg.isValid
-			validationResults[validator.Address] = msg.isValidTwo
-			if msg.isValid == true {
-				validCount++
-			} else {
-				invalidCount++
-			}
-			if msg.isValidTwo == true {
-				validTwoCount++
-			} else {
-				invalidTwoCount++
-			}
-		default:
-			fmt.Printf("Received an unknown struct: %+v\n", msg)
-			fmt.Printf("%T\n", msg)
+			fmt.Fprintln(sim.out, err.Error())
+			continue
 		}
+		fmt.Fprintf(sim.out, "Leader %s also proposes block %d\n", leader.Address[:3], block.Index)
+		proposals = append(proposals, &Proposal{Block: block, Proposer: leader, Accepted: true, Recipients: sim.validators})
 	}
-
-	if currAttack == "network_partition" && (forked || evilProposer) {
-		// fmt.Printf("Voting results\nInvalid Count: %d\nValid Count: %d\nInvalid Two Count: %d\nValid Two Count: %d\nCommittee size: %d\n", invalidCount, validCount, invalidTwoCount, validTwoCount, len(validationCommittee))
-	} else {
-		// fmt.Printf("Voting results\nInvalid Count: %d\nValid Count: %d\nCommittee size: %d\n", invalidCount, validCount, len(validationCommittee))
-	}
-
-	//chain is forked
-	if forked {
-		println("Chain is forked")
-		isValid := validCount >= len(validationCommittee)/2
-		if isValid {
-			//broadcast the verified transactions to only right branch-- branch with proposer
-			for _, validator := range validators {
-				if slices.Contains(ForkedBlockchain[proposerGroup], validator) {
-					msg := VerifiedShortAttackBlockMessage{
-						transactions: newBlock.Transactions,
-						newBlock:     newBlock,
-					}
-					validator.incomingChannel <- msg
-				}
-			}
-
-			//Update transactional amounts and reward proposer
-			for _, transaction := range newBlock.Transactions {
-				transaction.Sender.Balance -= (transaction.Amount + transaction.Reward)
-				transaction.Receiver.Balance += transaction.Amount
-				proposer.Stake += transaction.Reward
-
-				senderString := fmt.Sprintf("New balance: %f\n", transaction.Sender.Balance)
-				io.WriteString(transaction.Sender.conn, senderString)
-
-				receiverString := fmt.Sprintf("New balance: %f\n", transaction.Receiver.Balance)
-				io.WriteString(transaction.Receiver.conn, receiverString)
-			}
-			println("Valid block added to blockchain")
-		} else {
-			println("Committee votes block invalid")
-			if blockchainType == "slashing" {
-				proposer.Stake *= 0.2
-			}
-		}
-
-		if blockchainType == "slashing" {
-			slashPercentage := 0.2
-			for _, validator := range validationCommittee {
-				if isValid {
-					if validationResults[validator.Address] == false {
-						validator.Stake *= slashPercentage
-					}
-				} else {
-					if validationResults[validator.Address] == true {
-						validator.Stake *= slashPercentage
-					}
-				}
-			}
-		}
-		printInfo()
+	if len(proposals) == 0 {
 		return
 	}
-
-	//short range attack
-	if currAttack == "network_partition" && evilProposer {
-		isValid := validCount >= len(validationCommittee)/2
-		isValidTwo := validTwoCount >= len(validationCommittee)/2
-
-		if isValid {
-			//broadcast the verified transactions to all blocks within proposer's group
-			for _, validator := range validators {
-				if slices.Contains(ForkedBlockchain[proposerGroup], validator) {
-					msg := VerifiedShortAttackBlockMessage{
-						transactions: newBlock.Transactions,
-						newBlock:     newBlock,
-					}
-					validator.incomingChannel <- msg
-				}
-			}
-
-			//Update transactional amounts and reward proposer
-			for _, transaction := range newBlock.Transactions {
-				transaction.Sender.Balance -= (transaction.Amount + transaction.Reward)
-				transaction.Receiver.Balance += transaction.Amount
-				proposer.Stake += transaction.Reward
-
-				senderString := fmt.Sprintf("New balance: %f\n", transaction.Sender.Balance)
-				io.WriteString(transaction.Sender.conn, senderString)
-
-				receiverString := fmt.Sprintf("New balance: %f\n", transaction.Receiver.Balance)
-				io.WriteString(transaction.Receiver.conn, receiverString)
-			}
-			println("Valid block added to blockchain")
-		} else {
-			println("Committee votes block invalid")
-			if blockchainType == "slashing" {
-				proposer.Stake *= 0.2
-			}
-		}
-		if isValidTwo {
-			//broadcast the verified transactions to all blocks not witihin proposer's group
-			for _, validator := range validators {
-				if !slices.Contains(ForkedBlockchain[proposerGroup], validator) {
-					msg := VerifiedShortAttackBlockTwoMessage{
-						transactions: newBlockTwo.Transactions,
-						newBlockTwo:  newBlockTwo,
-					}
-					validator.incomingChannel <- msg
+	if len(slot.Proposals) == 1 && !slot.Proposals[0].Forced {
+		heard := append([]*Proposal{slot.Proposals[0]}, proposals...)
+		recipients := make([][]*Validator, len(heard))
+		for _, validator := range sim.validators {
+			first := sim.rng.Intn(len(heard))
+			for i, proposal := range heard {
+				if proposal.Proposer == validator {
+					first = i
 				}
 			}
-
-			//Update transactional amounts and reward proposer
-			for _, transaction := range newBlockTwo.Transactions {
-				transaction.Sender.Balance -= (transaction.Amount + transaction.Reward)
-				transaction.Receiver.Balance += transaction.Amount
-				proposer.Stake += transaction.Reward
-
-				senderString := fmt.Sprintf("New balance: %f\n", transaction.Sender.Balance)
-				io.WriteString(transaction.Sender.conn, senderString)
-
-				receiverString := fmt.Sprintf("New balance: %f\n", transaction.Receiver.Balance)
-				io.WriteString(transaction.Receiver.conn, receiverString)
-			}
-			println("Valid block added to blockchain")
-		} else {
-			println("Committee votes block invalid")
-			if blockchainType == "slashing" {
-				proposer.Stake *= 0.2
-			}
+			recipients[first] = append(recipients[first], validator)
 		}
-		if isValid && isValidTwo {
-			forked = true
-			forkProposer = proposer
-		}
-		//punish validators who voted against the majority
-		// slashPercentage := 0.2
-		// for _, validator := range validationCommittee {
-		// 	if isValid {
-		// 		if validationResults[validator.Address] == false {
-		// 			validator.Stake *= slashPercentage
-		// 		}
-		// 	} else {
-		// 		if validationResults[validator.Address] == true {
-		// 			validator.Stake *= slashPercentage
-		// 		}
-		// 	}
-
-		// 	if isValidTwo {
-		// 		if validationResultsTwo[validator.Address] == false {
-		// 			validator.Stake *= slashPercentage
-		// 		}
-		// 	} else {
-		// 		if validationResultsTwo[validator.Address] == true {
-		// 			validator.Stake *= slashPercentage
-		// 		}
-		// 	}
-		// }
-		printInfo()
-		return
-	}
-
-	isValid := validCount >= len(validationCommittee)/2
-	if isValid {
-		// proposer.Blockchain = append(proposer.Blockchain, newBlock)
-		println("Valid block added to blockchain")
-		proposer.blockSuccessCount += 1
-
-		//broadcast the verified transactions to all blocks
-		msg := VerifiedBlockMessage{
-			transactions: newBlock.Transactions,
-			newBlock:     newBlock,
-		}
-		for _, validator := range validators {
-			validator.incomingChannel <- msg
-		}
-
-		//Update transactional amounts and reward proposer
-		for _, transaction := range newBlock.Transactions {
-			transaction.Sender.Balance -= (transaction.Amount + transaction.Reward)
-			transaction.Receiver.Balance += transaction.Amount
-			proposer.Stake += transaction.Reward
-
-			senderString := fmt.Sprintf("New balance: %f\n", transaction.Sender.Balance)
-			io.WriteString(transaction.Sender.conn, senderString)
-
-			receiverString := fmt.Sprintf("New balance: %f\n", transaction.Receiver.Balance)
-			io.WriteString(transaction.Receiver.conn, receiverString)
-		}
-	} else {
-		println("Committee votes block invalid")
-		if blockchainType == "slashing" {
-			proposer.Stake *= 0.2
+		for i, proposal := range heard {
+			proposal.Recipients = recipients[i]
 		}
 	}
-	//punish validators who voted against the majority
-	slashPercentage := 0.2
-	for _, validator := range validationCommittee {
-		if isValid {
-			if validationResults[validator.Address] == false {
-				println("VALIDATED FALSE WHEN IT WAS TRUE")
-				if blockchainType == "slashing" {
-					validator.Stake *= slashPercentage
-				}
-			}
-		} else {
-			if validationResults[validator.Address] == true {
-				println("VALIDATED TRUE WHEN IT WAS FALSE")
-				if blockchainType == "slashing" {
-					validator.Stake *= slashPercentage
-				}
-			}
-		}
-	}
-	printInfo()
+	slot.Proposals = append(slot.Proposals, proposals...)
 }
 
-func balanceReputationNextTimeSlot() {
-	//wait 5 seconds every slot
-	time.Sleep(5 * time.Second)
-	fmt.Printf("\nTime slot %s\n\n", time.Now().Format("15:04:05"))
-	runConsensusCounter += 1
+// appliedTransaction is a transaction an accepted block settled and the
+// proposer of the block, which earned its reward
+type appliedTransaction struct {
+	transaction Transaction
+	proposer    *Validator
+	// Time slots the transaction waited for the block, see recordInclusion
+	wait int
+}
 
-	if runConsensusCounter >= 5 {
-		balanceLongestChainConsensus()
-		runConsensusCounter = 0
-	}
+// acceptBlock delivers an accepted proposal to its recipients, rewards the
+// proposer and settles the block's transactions that no block settled yet
+func (sim *Simulation) acceptBlock(proposal *Proposal) {
+	fmt.Fprintln(sim.out, "Valid block added to blockchain")
+	proposal.Proposer.blockSuccessCount += 1
+	sim.protocol.RewardProposer(sim, proposal.Proposer)
 
-	//Choose new delegates
-	if delegateCounter == 2*delegateSize {
-		delegateCounter = 0
-		delegates = chooseDelegates(validators, delegateSize)
-		fmt.Println("New delegates chosen")
+	var msg interface{} = VerifiedBlockMessage{
+		transactions: proposal.Block.Transactions,
+		newBlock:     proposal.Block,
 	}
-
-	//Choose next sequential block proposer from delegates
-	proposer = delegates[delegateCounter%delegateSize]
-	delegateCounter += 1
-	proposer.proposerCount += 1
-	fmt.Printf("Proposer %s chosen as new block proposer\n", proposer.Address[:3])
-
-	// find length of the shorter fork
-	validatorsSliceLock.Lock()
-	shorterForkLength := math.MaxInt32
-	fmt.Println("printing validator blockchains")
-	for _, validator := range validators {
-		if len(validator.Blockchain) < shorterForkLength {
-			shorterForkLength = len(validator.Blockchain)
+	if proposal.Forced {
+		msg = VerifiedShortAttackBlockMessage{
+			transactions: proposal.Block.Transactions,
+			newBlock:     proposal.Block,
 		}
 	}
-	validatorsSliceLock.Unlock()
-
-	//block proposer chooses a new block
-	newBlock, err := generateBlock(proposer)
-	if err != nil {
-		fmt.Println(err.Error())
-		return
-	}
-
-	fmt.Printf("Block %d chosen as new block\n", newBlock.Index)
-
-	//let malicious validators know if they should vote for/against block to balance
-	malVote := false
-	if len(proposer.Blockchain) == shorterForkLength {
-		malVote = true
+	for _, validator := range proposal.Recipients {
+		validator.receive(msg)
 	}
 
-	//validation committee validates blocks
-	//broadcast block to all members of committee
-	for _, validator := range delegates {
-		msg := ValidateBlockMessage{
-			newBlock: newBlock,
-			malVote:  malVote,
-		}
-		validator.incomingChannel <- msg
-	}
-
-	// Process validation results
-	validCount := 0
-	invalidCount := 0
-	validationResults := make(map[string]bool)
-	for _, validator := range delegates {
-		msg := <-validator.outgoingChannel
-		switch msg := msg.(type) { // Use type assertion to determine the type of the received message
-		case ValidationStatusMessage:
-			validationResults[validator.Address] = msg.isValid
-			if msg.isValid == true {
-				validCount++
-			} else {
-				invalidCount++
-			}
-		default:
-			fmt.Printf("Received an unknown struct: %+v\n", msg)
-			fmt.Printf("%T\n", msg)
-		}
-	}
-
-	// fmt.Printf("Voting results\nInvalid Count: %d\nValid Count: %d\nCommittee size: %d\n", invalidCount, validCount, len(delegates))
-
-	//add block if majority believe block is valid
-	isValid := validCount > len(delegates)/2
-	if isValid {
-		println("Valid block added to blockchain")
-		proposer.blockSuccessCount += 1
-		proposer.reputation = math.Min(100, proposer.reputation+1)
-		//broadcast the verified transactions to all blocks
-		msg := VerifiedBlockMessage{
-			transactions: newBlock.Transactions,
-			newBlock:     newBlock,
+	//Update transactional amounts and reward proposer
+	for _, transaction := range proposal.Block.Transactions {
+		//the block of another leader or branch may have settled it already
+		if _, ok := sim.applied[transaction.ID]; ok {
+			continue
 		}
-		for _, validator := range validators {
-			validator.incomingChannel <- msg
+		sim.applied[transaction.ID] = appliedTransaction{transaction: transaction, proposer: proposal.Proposer, wait: sim.recordInclusion(transaction)}
+		if len(transaction.Candidates) > 0 {
+			transaction.Sender.Balance -= transaction.Reward
+			proposal.Proposer.Stake += transaction.Reward
+			sim.castDelegateVote(transaction)
+			continue
 		}
+		transaction.Sender.Balance -= (transaction.Amount + transaction.Reward)
+		transaction.Receiver.Balance += transaction.Amount
+		proposal.Proposer.Stake += transaction.Reward
 
-		//Update transactional amounts and reward proposer
-		for _, transaction := range newBlock.Transactions {
-			transaction.Sender.Balance -= (transaction.Amount + transaction.Reward)
-			transaction.Receiver.Balance += transaction.Amount
-			proposer.Stake += transaction.Reward
-
-			senderString := fmt.Sprintf("New balance: %f\n", transaction.Sender.Balance)
-			io.WriteString(transaction.Sender.conn, senderString)
+		senderString := fmt.Sprintf("New balance: %f\n", transaction.Sender.Balance)
+		io.WriteString(transaction.Sender.out, senderString)
 
-			receiverString := fmt.Sprintf("New balance: %f\n", transaction.Receiver.Balance)
-			io.WriteString(transaction.Receiver.conn, receiverString)
-		}
-	} else {
-		println("Committee votes block invalid")
-		proposer.reputation *= 0.2
-	}
-	//punish validators who voted against the majority
-	for _, validator := range delegates {
-		if isValid {
-			//Block was valid, but voted invalid
-			if validationResults[validator.Address] == false {
-				validator.reputation *= 0.5
-			} else {
-				validator.reputation = math.Min(100, 1+validator.reputation)
-			}
-		} else {
-			//Block invalid, but voted valid
-			if validationResults[validator.Address] == true {
-				validator.reputation *= 0.5
-			} else {
-				validator.reputation = math.Min(100, 1+validator.reputation)
-			}
-		}
+		receiverString := fmt.Sprintf("New balance: %f\n", transaction.Receiver.Balance)
+		io.WriteString(transaction.Receiver.out, receiverString)
 	}
-
-	balancePrintInfo()
-
+	sim.attack.OnBlockAccepted(sim, proposal)
 }
 
-func nextReputationTimeSlot() {
-	//wait 5 seconds every slot
-	time.Sleep(5 * time.Second)
-	fmt.Printf("\nTime slot %s\n\n", time.Now().Format("15:04:05"))
-	runConsensusCounter += 1
-
-	if runConsensusCounter >= 5 {
-		longestChainConsensus()
-		runConsensusCounter = 0
-	}
-
-	//Choose new delegates
-	if delegateCounter == 2*delegateSize {
-		delegateCounter = 0
-		delegates = chooseDelegates(validators, delegateSize)
-		fmt.Println("New delegates chosen")
-	}
-
-	//Choose next sequential block proposer from delegates
-	proposer = delegates[delegateCounter%delegateSize]
-	delegateCounter += 1
-	proposer.proposerCount += 1
-	fmt.Printf("Proposer %s chosen as new block proposer\n", proposer.Address[:3])
-
-	//block proposer chooses a new block
-
-	//check what group proposer is in
-	proposerGroup := 1
-	if slices.Contains(ForkedBlockchain[0], proposer) {
-		proposerGroup = 0
-	}
-
-	newBlock, err := generateBlock(proposer)
-	if err != nil {
-		fmt.Println(err.Error())
-		return
-	}
-
-	evilProposer := false
-
-	if proposer.IsMalicious {
-		evilProposer = true
-	}
-
-	var newBlockTwo Block
-	if currAttack == "network_partition" && evilProposer {
-		println("EVIL PROPOSER DOING WORK")
-		newBlockTwo, err = generateBlock(proposer)
-		if err != nil {
-			fmt.Println(err.Error())
-			return
-		}
-	} else {
-		newBlockTwo = Block{}
-	}
-
-	fmt.Printf("Block %d chosen as new block\n", newBlock.Index)
-
-	//validation committee validates blocks
-	//broadcast block to all members of committee
-	for _, validator := range delegates {
-		if currAttack == "network_partition" && evilProposer && !forked {
-			if evilProposer {
-				msg := ValidateShortAttackBlockMessage{
-					newBlock:    newBlock,
-					newBlockTwo: newBlockTwo,
-				}
-				validator.incomingChannel <- msg
-			}
-		} else {
-			msg := ValidateBlockMessage{
-				newBlock: newBlock,
-			}
-			validator.incomingChannel <- msg
+// revertOrphanedTransactions undoes the transactions accepted blocks settled
+// that the certified chain dropped, and hands the reward of those it keeps in
+// a block of another proposer to that proposer. A vote stays cast, as the
+// user's next vote replaces it anyway.
+func (sim *Simulation) revertOrphanedTransactions() {
+	proposers := make(map[int]string)
+	for _, block := range sim.CertifiedBlockchain {
+		for _, transaction := range block.Transactions {
+			proposers[transaction.ID] = block.Validator
 		}
 	}
-
-	// Process validation results
-	validCount := 0
-	invalidCount := 0
-	validTwoCount := 0
-	invalidTwoCount := 0
-	validationResults := make(map[string]bool)
-	for _, validator := range delegates {
-		msg := <-validator.outgoingChannel
-		switch msg := msg.(type) { // Use type assertion to determine the type of the received message
-		case ValidationStatusMessage:
-			validationResults[validator.Address] = msg.isValid
-			if msg.isValid == true {
-				validCount++
-			} else {
-				invalidCount++
-			}
-		case ValidationShortAttackStatusMessage:
-			validationResults[validator.Address] = msg.isValid
-			validationResults[validator.Address] = msg.isValidTwo
-			if msg.isValid == true {
-				validCount++
-			} else {
-				invalidCount++
-			}
-			if msg.isValidTwo == true {
-				validTwoCount++
-			} else {
-				invalidTwoCount++
-			}
-		default:
-			fmt.Printf("Received an unknown struct: %+v\n", msg)
-			fmt.Printf("%T\n", msg)
-		}
+	//go through the transactions in order so balances add up the same way
+	ids := make([]int, 0, len(sim.applied))
+	for id := range sim.applied {
+		ids = append(ids, id)
 	}
-	// fmt.Printf("Voting results\nInvalid Count: %d\nValid Count: %d\nCommittee size: %d\n", invalidCount, validCount, len(validationCommittee))
-	if currAttack == "network_partition" && (forked || evilProposer) {
-		// fmt.Printf("Voting results\nInvalid Count: %d\nValid Count: %d\nInvalid Two Count: %d\nValid Two Count: %d\nCommittee size: %d\n", invalidCount, validCount, invalidTwoCount, validTwoCount, len(delegates))
-	} else {
-		// fmt.Printf("Voting results\nInvalid Count: %d\nValid Count: %d\nCommittee size: %d\n", invalidCount, validCount, len(delegates))
-	}
-
-	//chain is forked
-	if forked {
-		println("Chain is forked")
-
-		//add block if majority believe block is valid
-		isValid := validCount >= len(delegates)/2
-		if isValid {
-			println("Valid block added to blockchain")
-			proposer.blockSuccessCount += 1
-			proposer.reputation = math.Min(100, proposer.reputation+1)
-			//broadcast the verified transactions to all blocks
-			for _, validator := range validators {
-				if slices.Contains(ForkedBlockchain[proposerGroup], validator) {
-					msg := VerifiedShortAttackBlockMessage{
-						transactions: newBlock.Transactions,
-						newBlock:     newBlock,
-					}
-					validator.incomingChannel <- msg
-				}
+	sort.Ints(ids)
+	for _, id := range ids {
+		applied := sim.applied[id]
+		transaction := applied.transaction
+		if address, kept := proposers[id]; kept {
+			if address == applied.proposer.Address {
+				continue
 			}
-
-			//Update transactional amounts and reward proposer
-			for _, transaction := range newBlock.Transactions {
-				transaction.Sender.Balance -= (transaction.Amount + transaction.Reward)
-				transaction.Receiver.Balance += transaction.Amount
+			if proposer := sim.validatorByAddress(address); proposer != nil {
+				applied.proposer.Stake -= transaction.Reward
 				proposer.Stake += transaction.Reward
-
-				senderString := fmt.Sprintf("New balance: %f\n", transaction.Sender.Balance)
-				io.WriteString(transaction.Sender.conn, senderString)
-
-				receiverString := fmt.Sprintf("New balance: %f\n", transaction.Receiver.Balance)
-				io.WriteString(transaction.Receiver.conn, receiverString)
+				sim.applied[id] = appliedTransaction{transaction: transaction, proposer: proposer, wait: applied.wait}
 			}
-		} else {
-			println("Committee votes block invalid")
-			proposer.reputation *= 0.2
+			continue
 		}
-		//punish validators who voted against the majority
-		for _, validator := range delegates {
-			if isValid {
-				//Block was valid, but voted invalid
-				if validationResults[validator.Address] == false {
-					validator.reputation *= 0.5
-				} else {
-					validator.reputation = math.Min(100, 1+validator.reputation)
-				}
-			} else {
-				//Block invalid, but voted valid
-				if validationResults[validator.Address] == true {
-					validator.reputation *= 0.5
-				} else {
-					validator.reputation = math.Min(100, 1+validator.reputation)
-				}
-			}
+		applied.proposer.Stake -= transaction.Reward
+		transaction.Sender.Balance += transaction.Reward
+		if len(transaction.Candidates) == 0 {
+			transaction.Sender.Balance += transaction.Amount
+			transaction.Receiver.Balance -= transaction.Amount
 		}
-
-		printInfo()
-		return
+		sim.revertInclusion(applied)
+		delete(sim.applied, id)
 	}
+}
 
-	//short range attack
-	if currAttack == "network_partition" && evilProposer {
-		isValid := validCount >= len(delegates)/2
-		isValidTwo := validTwoCount >= len(delegates)/2
-
-		if isValid {
-			//broadcast the verified transactions to all blocks within proposer's group
-			println("Valid block added to blockchain")
-			proposer.blockSuccessCount += 1
-			proposer.reputation = math.Min(100, proposer.reputation+1)
-			//broadcast the verified transactions to all blocks
-			for _, validator := range validators {
-				if slices.Contains(ForkedBlockchain[proposerGroup], validator) {
-					msg := VerifiedBlockMessage{
-						transactions: newBlock.Transactions,
-						newBlock:     newBlock,
-					}
-					validator.incomingChannel <- msg
-				}
-			}
-
-			//Update transactional amounts and reward proposer
-			for _, transaction := range newBlock.Transactions {
-				transaction.Sender.Balance -= (transaction.Amount + transaction.Reward)
-				transaction.Receiver.Balance += transaction.Amount
-				proposer.Stake += transaction.Reward
-
-				senderString := fmt.Sprintf("New balance: %f\n", transaction.Sender.Balance)
-				io.WriteString(transaction.Sender.conn, senderString)
-
-				receiverString := fmt.Sprintf("New balance: %f\n", transaction.Receiver.Balance)
-				io.WriteString(transaction.Receiver.conn, receiverString)
-			}
-		} else {
-			println("Committee votes block invalid")
-			proposer.reputation *= 0.2
-		}
-		if isValidTwo {
-			println("Valid block added to blockchain")
-			proposer.blockSuccessCount += 1
-			proposer.reputation = math.Min(100, proposer.reputation+1)
-			//broadcast the verified transactions to all blocks not witihin proposer's group
-			for _, validator := range validators {
-				if !slices.Contains(ForkedBlockchain[proposerGroup], validator) {
-					msg := VerifiedShortAttackBlockTwoMessage{
-						transactions: newBlockTwo.Transactions,
-						newBlockTwo:  newBlockTwo,
-					}
-					validator.incomingChannel <- msg
-				}
-			}
+func (sim *Simulation) printInfo() {
+	// println("Delegates")
+	// for _, delegate := range delegates {
+	// 	println(delegate.Address[:3])
+	// }
 
-			//Update transactional amounts and reward proposer
-			for _, transaction := range newBlockTwo.Transactions {
-				transaction.Sender.Balance -= (transaction.Amount + transaction.Reward)
-				transaction.Receiver.Balance += transaction.Amount
-				proposer.Stake += transaction.Reward
+	fmt.Fprintln(sim.out, "BLOCKCHAIN")
+	fmt.Fprintln(sim.out, chainString(sim.CertifiedBlockchain))
 
-				senderString := fmt.Sprintf("New balance: %f\n", transaction.Sender.Balance)
-				io.WriteString(transaction.Sender.conn, senderString)
+	//prints User balances
+	// println("User balances")
+	// for user := range users {
+	// 	fmt.Printf("%s: %f\n", users[user].Name, users[user].Balance)
+	// }
 
-				receiverString := fmt.Sprintf("New balance: %f\n", transaction.Receiver.Balance)
-				io.WriteString(transaction.Receiver.conn, receiverString)
-			}
-			println("Valid block added to blockchain")
-		} else {
-			println("Committee votes block invalid")
-			proposer.reputation *= 0.2
+	//prints Validator balances, and their chains while they disagree
+	heads := sim.chainHeads()
+	fmt.Fprintln(sim.out, "Validator balances")
+	for _, validator := range sim.validators {
+		fmt.Fprintf(sim.out, "%s: %f, %f, Evil: %t\n", validator.Address[:3], validator.Stake, validator.reputation, validator.IsMalicious)
+		if len(heads) > 1 {
+			fmt.Fprintf(sim.out, "VALIDATOR %s BLOCKCHAIN\n", validator.Address[:3])
+			fmt.Fprintln(sim.out, chainString(validator.Blockchain))
 		}
-		if isValid && isValidTwo {
-			forked = true
-			forkProposer = proposer
-		}
-		printInfo()
-		return
 	}
+}
 
-	//add block if majority believe block is valid
-	isValid := validCount >= len(delegates)/2
-	if isValid {
-		println("Valid block added to blockchain")
-		proposer.blockSuccessCount += 1
-		proposer.reputation = math.Min(100, proposer.reputation+1)
-		//broadcast the verified transactions to all blocks
-		msg := VerifiedBlockMessage{
-			transactions: newBlock.Transactions,
-			newBlock:     newBlock,
-		}
-		for _, validator := range validators {
-			validator.incomingChannel <- msg
+// chainHeads returns the distinct heads of the validators' chains, in the
+// order validators joined
+func (sim *Simulation) chainHeads() []Block {
+	heads := make([]Block, 0, 1)
+	seen := make(map[string]bool)
+	for _, validator := range sim.validators {
+		head := validator.Blockchain[len(validator.Blockchain)-1]
+		if !seen[head.Hash] {
+			seen[head.Hash] = true
+			heads = append(heads, head)
 		}
+	}
+	return heads
+}
 
-		//Update transactional amounts and reward proposer
-		for _, transaction := range newBlock.Transactions {
-			transaction.Sender.Balance -= (transaction.Amount + transaction.Reward)
-			transaction.Receiver.Balance += transaction.Amount
-			proposer.Stake += transaction.Reward
-
-			senderString := fmt.Sprintf("New balance: %f\n", transaction.Sender.Balance)
-			io.WriteString(transaction.Sender.conn, senderString)
+// slashEquivocators slashes by slashRatio the proposer of slot for signing
+// two blocks and the committee members that voted for both
+func (sim *Simulation) slashEquivocators(slot *Slot) {
+	equivocators := append([]*Validator{slot.Proposer}, slot.Votes.DoubleVoters...)
+	for _, validator := range equivocators {
+		fmt.Fprintf(sim.out, "Validator %s slashed for equivocating\n", validator.Address[:3])
+		validator.Stake *= sim.scenario.SlashRatio
+		sim.security.SlashedEquivocators++
+	}
+}
 
-			receiverString := fmt.Sprintf("New balance: %f\n", transaction.Receiver.Balance)
-			io.WriteString(transaction.Receiver.conn, receiverString)
-		}
-	} else {
-		println("Committee votes block invalid")
-		proposer.reputation *= 0.2
-	}
-	//punish validators who voted against the majority
-	for _, validator := range delegates {
-		if isValid {
-			//Block was valid, but voted invalid
-			if validationResults[validator.Address] == false {
-				validator.reputation *= 0.5
-			} else {
-				validator.reputation = math.Min(100, 1+validator.reputation)
-			}
-		} else {
-			//Block invalid, but voted valid
-			if validationResults[validator.Address] == true {
-				validator.reputation *= 0.5
-			} else {
-				validator.reputation = math.Min(100, 1+validator.reputation)
-			}
+// chainString lists the transaction IDs of every block of chain
+func chainString(chain []Block) string {
+	printString := ""
+	for _, block := range chain {
+		printString += "->["
+		for _, transaction := range block.Transactions {
+			printString += fmt.Sprintf("%d,", transaction.ID)
 		}
+		printString = printString[:len(printString)-1]
+		printString += "]"
 	}
+	return printString[1:]
+}
 
-	printInfo()
+// Evaluation summarises the certified blockchain of a run
+type Evaluation struct {
+	TotalBlocks           int     `json:"totalBlocks"`
+	MaliciousBlocks       int     `json:"maliciousBlocks"`
+	TransactionsValidated int     `json:"transactionsValidated"`
+	ElapsedSeconds        float64 `json:"elapsedSeconds"`
+	// Blocks of the certified chain no consensus round can revert
+	FinalizedBlocks int `json:"finalizedBlocks"`
+	// Outcome of the attack, see SecurityReport
+	Security SecurityReport `json:"security"`
+}
 
+func (sim *Simulation) evaluate() Evaluation {
+	malBlockCount := 0
+	transactionCount := 0
+	for _, block := range sim.CertifiedBlockchain {
+		if block.IsMalicious {
+			malBlockCount++
+		}
+		transactionCount += len(block.Transactions)
+	}
+	return Evaluation{
+		TotalBlocks:           len(sim.CertifiedBlockchain),
+		MaliciousBlocks:       malBlockCount,
+		TransactionsValidated: transactionCount,
+		FinalizedBlocks:       len(sim.FinalizedBlockchain()),
+		ElapsedSeconds:        sim.clock.Now().Sub(sim.startTime).Seconds(),
+		Security:              sim.securityReport(),
+	}
 }
 
-func handleConnection(conn net.Conn, runType string, connectionType string, malString string, splitView bool) {
-	defer conn.Close()
+func (sim *Simulation) printEvaluation() {
+	//print malicious nodes
+	evaluation := sim.evaluate()
 
-	//Determine user or validator connection
-	io.WriteString(conn, "Is this node a user or validator (u/v)\n")
-	scannedType := bufio.NewScanner(conn)
-	if runType == "auto" {
-		scannedType = bufio.NewScanner(strings.NewReader(connectionType))
-	}
-	for scannedType.Scan() {
-		if scannedType.Text() == "u" {
-			handleUserConnection(conn, runType)
-		} else if scannedType.Text() == "v" {
-			handleValidatorConnection(conn, runType, malString, splitView)
-		} else {
-			fmt.Printf("%s is not a valid response\n Please enter 'u' or 'v' ", scannedType.Text())
-		}
-		break
+	fmt.Fprint(sim.out, "\nRESULTS\n\n")
+	fmt.Fprintf(sim.out, "Total blocks: %d\n", evaluation.TotalBlocks)
+	fmt.Fprintf(sim.out, "Malicious blocks: %d\n", evaluation.MaliciousBlocks)
+	fmt.Fprintf(sim.out, "Transactions validated: %d\n", evaluation.TransactionsValidated)
+	if sim.finality != nil {
+		fmt.Fprintf(sim.out, "Finalized blocks: %d\n", evaluation.FinalizedBlocks)
 	}
+	fmt.Fprintf(sim.out, "Time so far: %f\n", evaluation.ElapsedSeconds)
 }
@@ -0,0 +1,152 @@
+package pos
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testScenario is a short headless run of the default scenario
+func testScenario(seed int64) Scenario {
+	s := DefaultScenario()
+	s.RunType = "headless"
+	s.Seed = seed
+	s.NumSlots = 40
+	return s
+}
+
+// newTestSimulation sets up s without reporting anything
+func newTestSimulation(t *testing.T, s Scenario) *Simulation {
+	t.Helper()
+	sim, err := NewSimulation(s, nil, io.Discard)
+	if err != nil {
+		t.Fatalf("NewSimulation: %v", err)
+	}
+	return sim
+}
+
+func simulate(t *testing.T, s Scenario) Results {
+	t.Helper()
+	results, err := newTestSimulation(t, s).Simulate(context.Background())
+	if err != nil {
+		t.Fatalf("Simulate: %v", err)
+	}
+	return results
+}
+
+func TestSimulateIsDeterministic(t *testing.T) {
+	for _, attack := range []string{"network_partition", "balance", "censorship"} {
+		s := testScenario(42)
+		s.Attack = attack
+		first := simulate(t, s)
+		second := simulate(t, s)
+		if !reflect.DeepEqual(first.Evaluation, second.Evaluation) {
+			t.Errorf("%s: runs with the same seed differ:\n%+v\n%+v", attack, first.Evaluation, second.Evaluation)
+		}
+	}
+}
+
+func TestConcurrentSimulationsDoNotInterfere(t *testing.T) {
+	scenarios := make([]Scenario, 0)
+	for _, blockchainType := range []string{"pos", "slashing", "reputation", "ouroboros", "hotstuff", "dpos"} {
+		s := testScenario(7)
+		s.BlockchainType = blockchainType
+		scenarios = append(scenarios, s)
+	}
+	sequential := make([]Evaluation, len(scenarios))
+	for i, s := range scenarios {
+		sequential[i] = simulate(t, s).Evaluation
+	}
+
+	concurrent := make([]Evaluation, len(scenarios))
+	var wg sync.WaitGroup
+	for i, s := range scenarios {
+		wg.Add(1)
+		go func(i int, s Scenario) {
+			defer wg.Done()
+			sim, err := NewSimulation(s, nil, io.Discard)
+			if err != nil {
+				t.Errorf("NewSimulation: %v", err)
+				return
+			}
+			results, err := sim.Simulate(context.Background())
+			if err != nil {
+				t.Errorf("Simulate: %v", err)
+				return
+			}
+			concurrent[i] = results.Evaluation
+		}(i, s)
+	}
+	wg.Wait()
+
+	for i, s := range scenarios {
+		if !reflect.DeepEqual(sequential[i], concurrent[i]) {
+			t.Errorf("%s: concurrent run differs from the sequential one:\n%+v\n%+v", s.BlockchainType, sequential[i], concurrent[i])
+		}
+	}
+}
+
+func TestCommitteeLargerThanValidatorsTakesEveryValidatorOnce(t *testing.T) {
+	//stakes whose weights do not add back up to zero once all are taken
+	stakes := []float64{749.3101345984675, 478.561513429506, 441.99063230209947, 474.9481518100123, 970.6848937036464, 504.65513156510787, 507.34786446663236, 979.2039893322797, 341.8712045575551, 305.02263458872403}
+	validators := make([]*Validator, len(stakes))
+	for i, stake := range stakes {
+		validators[i] = &Validator{Address: strconv.Itoa(i), Stake: stake}
+	}
+	for seed := int64(1); seed <= 20; seed++ {
+		sim := newTestSimulation(t, testScenario(seed))
+		committee := sim.chooseValidationCommittee(validators, 50)
+		members := make(map[*Validator]bool)
+		for _, member := range committee {
+			members[member] = true
+		}
+		if len(committee) != len(validators) || len(members) != len(validators) {
+			t.Errorf("seed %d: committee of %d with %d distinct members, want all %d validators once", seed, len(committee), len(members), len(validators))
+		}
+	}
+}
+
+func TestCommitteeLargerThanValidators(t *testing.T) {
+	for _, blockchainType := range []string{"pos", "slashing"} {
+		s := testScenario(3)
+		s.BlockchainType = blockchainType
+		s.Attack = "none"
+		s.CommitteeSize = 50
+		if results := simulate(t, s); results.Evaluation.TotalBlocks == 0 {
+			t.Errorf("%s: committee of every validator accepted no blocks", blockchainType)
+		}
+	}
+}
+
+func TestSimulateStopsAtTheFirstBound(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(s *Scenario)
+		reason string
+	}{
+		{"slots", func(s *Scenario) { s.NumSlots = 12 }, "slots"},
+		{"blocks", func(s *Scenario) { s.MaxBlocks = 3 }, "blocks"},
+		{"duration", func(s *Scenario) { s.Duration = Duration(7 * time.Duration(s.SlotDuration)) }, "duration"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := testScenario(1)
+			test.modify(&s)
+			results := simulate(t, s)
+			if results.StopReason != test.reason {
+				t.Errorf("stopped on %q after %d slots, want %q", results.StopReason, results.Slots, test.reason)
+			}
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err := newTestSimulation(t, testScenario(1)).Simulate(ctx)
+	if err != nil || results.StopReason != "cancelled" {
+		t.Errorf("cancelled run stopped on %q with error %v", results.StopReason, err)
+	}
+}
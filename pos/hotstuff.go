@@ -0,0 +1,265 @@
+package pos
+
+import "fmt"
+
+// hotstuffProtocol is chained HotStuff. Every time slot is one view with its
+// own leader, rotated round robin. The leader proposes a block extending the
+// block of the highest quorum certificate it knows, and every validator votes
+// for it if it is safe: it extends the block validators locked on, or carries
+// a certificate newer than the lock. Once more than 2/3 of the stake voted the
+// block is certified, and its certificate chains it to the blocks before it.
+// When a certified block and its parent were proposed in consecutive views
+// validators lock on the parent, and when its grandparent was proposed in the
+// view before that the grandparent and everything before it is committed, the
+// three-chain rule. A view without a certificate times out and the pacemaker
+// moves to the next leader, leaving a gap in the chain of views that holds
+// back commits until three consecutive views certify their blocks again.
+// Validators caught signing two blocks in one view are slashed.
+type hotstuffProtocol struct {
+	baseProtocol
+	// View in progress, whether it certified a block and whether its leader
+	// had no transactions to propose
+	view      int
+	certified bool
+	idle      bool
+	// Views in a row that timed out
+	timeouts int
+	// Blocks proposed since the genesis block, by hash
+	nodes map[string]*hotstuffNode
+	// Highest certificate, which leaders extend, and the certificate
+	// validators locked on
+	genericQC, lockedQC quorumCert
+	// Hash of the last block committed
+	committed string
+}
+
+// hotstuffNode is a proposed block with the certificate it extends
+type hotstuffNode struct {
+	block    Block
+	proposer *Validator
+	view     int
+	justify  quorumCert
+}
+
+// quorumCert certifies that more than 2/3 of the stake voted for a block in a
+// view
+type quorumCert struct {
+	view  int
+	hash  string
+	stake float64
+}
+
+func newHotstuffProtocol() *hotstuffProtocol {
+	return &hotstuffProtocol{nodes: make(map[string]*hotstuffNode)}
+}
+
+// start certifies the genesis block the first time a view starts
+func (p *hotstuffProtocol) start(sim *Simulation) {
+	genesis := sim.CertifiedBlockchain[0]
+	p.nodes[genesis.Hash] = &hotstuffNode{block: genesis}
+	p.genericQC = quorumCert{hash: genesis.Hash}
+	p.lockedQC = p.genericQC
+	p.committed = genesis.Hash
+	p.certified = true
+}
+
+func (p *hotstuffProtocol) SelectCommittee(sim *Simulation) []*Validator {
+	committee := make([]*Validator, len(sim.validators))
+	copy(committee, sim.validators)
+	return committee
+}
+
+// SelectProposer is the pacemaker: it times out the last view if its leader
+// proposed a block that was not certified and moves to the next view and its
+// leader
+func (p *hotstuffProtocol) SelectProposer(sim *Simulation, committee []*Validator) *Validator {
+	if p.committed == "" {
+		p.start(sim)
+	}
+	if !p.certified && !p.idle {
+		p.timeouts++
+		sim.security.Liveness.recordTimeout(p.timeouts)
+		fmt.Fprintf(sim.out, "View %d timed out\n", p.view)
+	}
+	p.view++
+	p.certified = false
+	p.idle = false
+	if len(committee) == 0 {
+		return nil
+	}
+	fmt.Fprintf(sim.out, "View %d\n", p.view)
+	return committee[p.view%len(committee)]
+}
+
+// ProposeBlock extends the block of the highest certificate with
+// transactions its ancestors do not hold yet
+func (p *hotstuffProtocol) ProposeBlock(sim *Simulation, proposer *Validator) (Block, error) {
+	parent := p.nodes[p.genericQC.hash]
+	block, err := sim.generateBlockOn(proposer, parent.block, p.pendingTransactions(parent.block.Hash))
+	if err != nil {
+		//an empty mempool leaves the view without a block, which is no timeout
+		p.idle = true
+		return block, err
+	}
+	p.nodes[block.Hash] = &hotstuffNode{block: block, proposer: proposer, view: p.view, justify: p.genericQC}
+	return block, nil
+}
+
+// pendingTransactions returns the transactions of the uncommitted blocks up to
+// the block with hash, by id
+func (p *hotstuffProtocol) pendingTransactions(hash string) map[int]bool {
+	pending := make(map[int]bool)
+	for node := p.nodes[hash]; node != nil && node.block.Hash != p.committed; node = p.nodes[node.block.PrevHash] {
+		for _, transaction := range node.block.Transactions {
+			pending[transaction.ID] = true
+		}
+	}
+	return pending
+}
+
+// extends reports whether the block with hash descends from the block with
+// ancestor
+func (p *hotstuffProtocol) extends(hash string, ancestor string) bool {
+	for node := p.nodes[hash]; node != nil; node = p.nodes[node.block.PrevHash] {
+		if node.block.Hash == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// safe is the voting rule of HotStuff: a block is safe if it extends the
+// locked block, or if it carries a certificate newer than the lock
+func (p *hotstuffProtocol) safe(block Block) bool {
+	node, ok := p.nodes[block.Hash]
+	if !ok {
+		return false
+	}
+	return p.extends(block.Hash, p.lockedQC.hash) || node.justify.view > p.lockedQC.view
+}
+
+// CollectVotes has every validator vote on the blocks of the view and
+// certifies a block once more than 2/3 of the stake voted for it
+func (p *hotstuffProtocol) CollectVotes(sim *Simulation, committee []*Validator, msg interface{}) Votes {
+	var blocks []Block
+	switch msg := msg.(type) {
+	case ValidateBlockMessage:
+		blocks = []Block{msg.newBlock}
+	case ValidateShortAttackBlockMessage:
+		blocks = []Block{msg.newBlock, msg.newBlockTwo}
+	}
+	//a second block of the leader extends the same certificate as the first
+	for _, block := range blocks[1:] {
+		if parent, ok := p.nodes[block.PrevHash]; ok && parent.block.Hash == p.genericQC.hash {
+			p.nodes[block.Hash] = &hotstuffNode{block: block, proposer: sim.proposer, view: p.view, justify: p.genericQC}
+		}
+	}
+	total := totalStake(committee)
+	doubleSigners := make(map[*Validator]bool)
+	if len(blocks) > 1 {
+		doubleSigners[sim.proposer] = true
+	}
+
+	//honest validators vote for the first safe block only
+	votes := Votes{Ballots: make(map[string]bool), Deferred: true}
+	stake := make([]float64, len(blocks))
+	for _, validator := range committee {
+		signed := 0
+		for i, block := range blocks {
+			vote := p.safe(block) && signed == 0
+			if validator.IsMalicious {
+				vote = sim.attack.OnVote(sim, validator, block, p.safe(block))
+			}
+			if i == 0 {
+				votes.Ballots[validator.Address] = vote
+			}
+			if !vote {
+				continue
+			}
+			stake[i] += validator.Stake
+			signed++
+			if i == 0 {
+				votes.Valid++
+			} else {
+				votes.ValidTwo++
+			}
+		}
+		if signed > 1 {
+			doubleSigners[validator] = true
+		}
+	}
+	votes.Invalid = len(committee) - votes.Valid
+	if len(blocks) > 1 {
+		votes.InvalidTwo = len(committee) - votes.ValidTwo
+	}
+	fmt.Fprintf(sim.out, "Votes %.0f%% of stake\n", 100*stake[0]/total)
+
+	//slash the evidence of double signing
+	for _, validator := range committee {
+		if doubleSigners[validator] {
+			fmt.Fprintf(sim.out, "Validator %s slashed for signing two blocks\n", validator.Address[:3])
+			validator.Stake *= sim.scenario.SlashRatio
+		}
+	}
+
+	for i, block := range blocks {
+		if _, ok := p.nodes[block.Hash]; ok && stake[i] > 2*total/3 && !p.certified {
+			fmt.Fprintf(sim.out, "Block %d certified in view %d\n", block.Index, p.view)
+			votes.Commits = p.certify(sim, quorumCert{view: p.view, hash: block.Hash, stake: stake[i]})
+		}
+	}
+	return votes
+}
+
+// direct reports whether child extends parent and was proposed in the view
+// right after it
+func direct(child *hotstuffNode, parent *hotstuffNode) bool {
+	return parent != nil && child.block.PrevHash == parent.block.Hash && child.view == parent.view+1
+}
+
+// certify applies the certificate qc: it becomes the highest certificate,
+// validators lock on the parent of its block if the two form a two-chain, and
+// the grandparent is committed if the three form a three-chain. It returns the
+// blocks committed, oldest first.
+func (p *hotstuffProtocol) certify(sim *Simulation, qc quorumCert) []*Proposal {
+	p.certified = true
+	p.timeouts = 0
+	p.genericQC = qc
+	sim.security.Liveness.CertifiedBlocks++
+
+	certified := p.nodes[qc.hash]
+	parent := p.nodes[certified.justify.hash]
+	if !direct(certified, parent) {
+		return nil
+	}
+	if certified.justify.view > p.lockedQC.view {
+		p.lockedQC = certified.justify
+	}
+	grandparent := p.nodes[parent.justify.hash]
+	if !direct(parent, grandparent) || grandparent.block.Hash == p.committed {
+		return nil
+	}
+
+	commits := make([]*Proposal, 0)
+	for node := grandparent; node != nil && node.block.Hash != p.committed; node = p.nodes[node.block.PrevHash] {
+		commits = append([]*Proposal{{Block: node.block, Proposer: node.proposer, Accepted: true, Recipients: sim.validators}}, commits...)
+	}
+	p.committed = grandparent.block.Hash
+	fmt.Fprintf(sim.out, "Three-chain commits block %d\n", grandparent.block.Index)
+	return commits
+}
+
+// RewardProposer leaves leaders with their transaction rewards only
+func (p *hotstuffProtocol) RewardProposer(sim *Simulation, proposer *Validator) {}
+
+// PunishProposer does nothing, a view without a certificate just times out
+func (p *hotstuffProtocol) PunishProposer(sim *Simulation, proposer *Validator) {}
+
+// SettleVotes does nothing, not voting is not an offence
+func (p *hotstuffProtocol) SettleVotes(sim *Simulation, committee []*Validator, votes Votes, accepted bool) {
+}
+
+// PunishForkProposer slashes the proposer of a fork, which breaks safety
+func (p *hotstuffProtocol) PunishForkProposer(sim *Simulation, proposer *Validator) {
+	proposer.Stake *= sim.scenario.SlashRatio
+}
@@ -0,0 +1,14 @@
+package pos
+
+import "testing"
+
+func TestHotstuffViewsWithoutTransactionsDoNotTimeOut(t *testing.T) {
+	s := testScenario(1)
+	s.BlockchainType = "hotstuff"
+	s.Attack = "none"
+	s.NumUsers = 0
+	liveness := simulate(t, s).Evaluation.Security.Liveness
+	if liveness.TimedOutViews != 0 {
+		t.Errorf("%d views timed out without transactions to propose", liveness.TimedOutViews)
+	}
+}
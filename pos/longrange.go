@@ -0,0 +1,118 @@
+package pos
+
+import "fmt"
+
+// retiredStakeRatio is the share of its initial stake below which a
+// validator counts as retired, with nothing left to lose by selling its keys
+const retiredStakeRatio = 0.05
+
+// longRange rewrites history with old keys. The attacker holds the keys of
+// malicious validators and buys those of retired validators, slashed to
+// almost nothing. Whenever a validator joins the network after the start, the
+// attacker forges a chain from longRangeForkHeight of the certified chain in
+// which only the validators it holds keys of propose, one block longer than
+// the certified chain as signing old blocks costs nothing, and offers it to
+// the joining validator next to the certified chain. The forged blocks carry
+// no transactions, erasing every transaction since the fork. Only joining
+// validators are offered the forged chain, so longest chain consensus keeps
+// it from the others.
+type longRange struct {
+	baseAttack
+	// Head of the forged chain every joining validator bootstrapped on, by
+	// address
+	captured map[string]string
+}
+
+func (l *longRange) OnValidatorJoin(sim *Simulation, validator *Validator) {
+	if sim.roundCount == 0 {
+		return
+	}
+	keys, retired := l.keys(sim)
+	honest := sim.CertifiedBlockchain
+	height := sim.scenario.LongRangeForkHeight
+	if len(keys) == 0 || height >= len(honest) {
+		return
+	}
+	forged := forgeChain(sim, honest[:height], keys, len(honest)+1)
+
+	sim.security.LongRange.LongRangeJoiners++
+	sim.security.LongRange.LongRangeKeys = len(keys)
+	sim.security.LongRange.RetiredKeys = retired
+	fmt.Fprintf(sim.out, "Long range attacker forges %d blocks from block %d with %d keys, %d of them retired\n", len(forged)-height, height, len(keys), retired)
+	if validator.bootstrap([][]Block{honest, forged}) == 1 {
+		if l.captured == nil {
+			l.captured = make(map[string]string)
+		}
+		l.captured[validator.Address] = forged[len(forged)-1].Hash
+		sim.security.LongRange.LongRangeCaptured++
+		fmt.Fprintf(sim.out, "Validator %s bootstraps on the forged chain\n", validator.Address[:3])
+	}
+}
+
+// OnConsensus picks the longest chain of the validators that did not
+// bootstrap on a forged chain if the chosen one did, as the others never saw
+// the forged blocks
+func (l *longRange) OnConsensus(sim *Simulation, chosen *Validator) *Validator {
+	if !l.holdsForged(chosen) {
+		return chosen
+	}
+	var longest *Validator
+	for _, validator := range sim.validators {
+		if !l.holdsForged(validator) && (longest == nil || len(validator.Blockchain) > len(longest.Blockchain)) {
+			longest = validator
+		}
+	}
+	if longest == nil {
+		return chosen
+	}
+	return longest
+}
+
+// holdsForged reports whether validator's chain still holds the forged chain
+// it bootstrapped on
+func (l *longRange) holdsForged(validator *Validator) bool {
+	head, ok := l.captured[validator.Address]
+	return ok && hasBlock(validator.Blockchain, head)
+}
+
+// keys returns the validators the attacker holds keys of, and how many of
+// them are retired rather than malicious
+func (l *longRange) keys(sim *Simulation) ([]*Validator, int) {
+	keys := make([]*Validator, 0)
+	retired := 0
+	for _, validator := range sim.validators {
+		switch {
+		case validator.IsMalicious:
+			keys = append(keys, validator)
+		case validator.Stake < retiredStakeRatio*validator.initialStake:
+			keys = append(keys, validator)
+			retired++
+		}
+	}
+	return keys, retired
+}
+
+// forgeChain extends prefix up to length blocks, signed in turn by keys
+func forgeChain(sim *Simulation, prefix []Block, keys []*Validator, length int) []Block {
+	chain := make([]Block, len(prefix), length)
+	copy(chain, prefix)
+	for len(chain) < length {
+		prev := chain[len(chain)-1]
+		signer := keys[len(chain)%len(keys)]
+		block := Block{
+			Index:       prev.Index + 1,
+			Timestamp:   sim.clock.Now().String(),
+			PrevHash:    prev.Hash,
+			Validator:   signer.Address,
+			IsMalicious: true,
+		}
+		//backdate the block to its height in the certified chain
+		if len(chain) < len(sim.CertifiedBlockchain) {
+			block.Timestamp = sim.CertifiedBlockchain[len(chain)].Timestamp
+		}
+		block.Hash = calculateBlockHash(block)
+		signBlock(&block, signer.privateKey)
+		chain = append(chain, block)
+	}
+	return chain
+}
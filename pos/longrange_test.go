@@ -0,0 +1,19 @@
+package pos
+
+import "testing"
+
+func TestLongRangeCapturesOnlySomeJoiners(t *testing.T) {
+	s := testScenario(3)
+	s.NumSlots = 60
+	s.Attack = "long_range"
+	s.JoinInterval = 10
+	r := simulate(t, s).Evaluation.Security.LongRange
+	if r.LongRangeJoiners == 0 {
+		t.Fatal("no validator joined after the start")
+	}
+	//the forged chain only wins while the keys behind it outweigh the stake
+	//signing the certified chain
+	if r.LongRangeCaptured == 0 || r.LongRangeCaptured == r.LongRangeJoiners {
+		t.Errorf("%d of %d joiners bootstrapped on the forged chain, want some but not all", r.LongRangeCaptured, r.LongRangeJoiners)
+	}
+}
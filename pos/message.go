@@ -6,7 +6,6 @@ type GenesisBlockMessage struct {
 
 type ValidateBlockMessage struct {
 	newBlock Block
-	malVote bool
 }
 
 type ValidateShortAttackBlockMessage struct {
@@ -41,11 +40,6 @@ type VerifiedShortAttackBlockMessage struct {
 	newBlock     Block
 }
 
-type VerifiedShortAttackBlockTwoMessage struct {
-	transactions []Transaction
-	newBlockTwo  Block
-}
-
 // type ConsensusMessage struct {
 // 	blockchain              []Block
 // 	unconfirmedTransactions map[int]Transaction
@@ -0,0 +1,258 @@
+package pos
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SlotMetrics is the state of the network at the end of a time slot
+type SlotMetrics struct {
+	Slot     int    `json:"slot"`
+	Time     string `json:"time"`
+	Proposer string `json:"proposer"`
+	// Slot leaders under ouroboros, the proposer first
+	Leaders   []string `json:"leaders,omitempty"`
+	Committee []string `json:"committee"`
+	// Votes on the proposed block, and on the second block of a malicious
+	// proposer during a network partition
+	ValidVotes      int `json:"validVotes"`
+	InvalidVotes    int `json:"invalidVotes"`
+	ValidTwoVotes   int `json:"validTwoVotes"`
+	InvalidTwoVotes int `json:"invalidTwoVotes"`
+	// Forked is set while a network partition splits the chain, ChainHeads
+	// counts the distinct chain tips validators currently see
+	Forked          bool `json:"forked"`
+	ChainHeads      int  `json:"chainHeads"`
+	TotalBlocks     int  `json:"totalBlocks"`
+	MaliciousBlocks int  `json:"maliciousBlocks"`
+	// Blocks of the certified chain a finality gadget made final, and the
+	// epochs of its highest justified and finalized checkpoints
+	FinalizedBlocks int                `json:"finalizedBlocks"`
+	JustifiedEpoch  int                `json:"justifiedEpoch"`
+	FinalizedEpoch  int                `json:"finalizedEpoch"`
+	Validators      []ValidatorMetrics `json:"validators"`
+	Users           []UserMetrics      `json:"users"`
+}
+
+// ValidatorMetrics is the state of one validator at the end of a time slot
+type ValidatorMetrics struct {
+	Address     string  `json:"address"`
+	IsMalicious bool    `json:"isMalicious"`
+	Stake       float64 `json:"stake"`
+	Reputation  float64 `json:"reputation"`
+	MempoolSize int     `json:"mempoolSize"`
+	ChainLength int     `json:"chainLength"`
+}
+
+// UserMetrics is how the transactions of one user fared by the end of a time
+// slot
+type UserMetrics struct {
+	Name     string `json:"name"`
+	Targeted bool   `json:"targeted,omitempty"`
+	Sent     int    `json:"sent"`
+	Included int    `json:"included"`
+	// Mean time slots from being sent to inclusion in an accepted block
+	MeanInclusionSlots float64 `json:"meanInclusionSlots"`
+}
+
+// MetricsWriter records the metrics of every time slot
+type MetricsWriter interface {
+	Write(metrics SlotMetrics) error
+	// Flush writes out anything still buffered
+	Flush() error
+}
+
+// NewMetricsWriter writes slot metrics to w as csv or jsonl
+func NewMetricsWriter(w io.Writer, format string) (MetricsWriter, error) {
+	switch format {
+	case "csv":
+		return &csvMetricsWriter{writer: csv.NewWriter(w)}, nil
+	case "jsonl":
+		return &jsonlMetricsWriter{encoder: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unknown metrics format %q, expected csv or jsonl", format)
+	}
+}
+
+// MetricsFormat guesses the metrics format from a file extension
+func MetricsFormat(path string) string {
+	if strings.ToLower(filepath.Ext(path)) == ".csv" {
+		return "csv"
+	}
+	return "jsonl"
+}
+
+type jsonlMetricsWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *jsonlMetricsWriter) Write(metrics SlotMetrics) error {
+	return w.encoder.Encode(metrics)
+}
+
+func (w *jsonlMetricsWriter) Flush() error {
+	return nil
+}
+
+// csvMetricsWriter writes one row per slot, flushed straight away so a server
+// that never stops still leaves a readable file. Per-validator values are
+// joined into address=value lists separated by semicolons.
+type csvMetricsWriter struct {
+	writer      *csv.Writer
+	wroteHeader bool
+}
+
+func (w *csvMetricsWriter) Write(metrics SlotMetrics) error {
+	if !w.wroteHeader {
+		err := w.writer.Write([]string{"slot", "time", "proposer", "leaders", "committee", "valid_votes", "invalid_votes", "valid_two_votes", "invalid_two_votes", "forked", "chain_heads", "total_blocks", "malicious_blocks", "finalized_blocks", "justified_epoch", "finalized_epoch", "malicious_validators", "stakes", "reputations", "mempool_sizes", "chain_lengths", "targeted_users", "pending_transactions", "inclusion_slots"})
+		if err != nil {
+			return err
+		}
+		w.wroteHeader = true
+	}
+
+	malicious := make([]string, 0)
+	stakes := make([]string, len(metrics.Validators))
+	reputations := make([]string, len(metrics.Validators))
+	mempoolSizes := make([]string, len(metrics.Validators))
+	chainLengths := make([]string, len(metrics.Validators))
+	for i, validator := range metrics.Validators {
+		if validator.IsMalicious {
+			malicious = append(malicious, validator.Address)
+		}
+		stakes[i] = validator.Address + "=" + strconv.FormatFloat(validator.Stake, 'f', -1, 64)
+		reputations[i] = validator.Address + "=" + strconv.FormatFloat(validator.Reputation, 'f', -1, 64)
+		mempoolSizes[i] = validator.Address + "=" + strconv.Itoa(validator.MempoolSize)
+		chainLengths[i] = validator.Address + "=" + strconv.Itoa(validator.ChainLength)
+	}
+
+	targeted := make([]string, 0)
+	pending := make([]string, len(metrics.Users))
+	inclusionSlots := make([]string, len(metrics.Users))
+	for i, user := range metrics.Users {
+		if user.Targeted {
+			targeted = append(targeted, user.Name)
+		}
+		pending[i] = user.Name + "=" + strconv.Itoa(user.Sent-user.Included)
+		inclusionSlots[i] = user.Name + "=" + strconv.FormatFloat(user.MeanInclusionSlots, 'f', -1, 64)
+	}
+
+	err := w.writer.Write([]string{
+		strconv.Itoa(metrics.Slot),
+		metrics.Time,
+		metrics.Proposer,
+		strings.Join(metrics.Leaders, ";"),
+		strings.Join(metrics.Committee, ";"),
+		strconv.Itoa(metrics.ValidVotes),
+		strconv.Itoa(metrics.InvalidVotes),
+		strconv.Itoa(metrics.ValidTwoVotes),
+		strconv.Itoa(metrics.InvalidTwoVotes),
+		strconv.FormatBool(metrics.Forked),
+		strconv.Itoa(metrics.ChainHeads),
+		strconv.Itoa(metrics.TotalBlocks),
+		strconv.Itoa(metrics.MaliciousBlocks),
+		strconv.Itoa(metrics.FinalizedBlocks),
+		strconv.Itoa(metrics.JustifiedEpoch),
+		strconv.Itoa(metrics.FinalizedEpoch),
+		strings.Join(malicious, ";"),
+		strings.Join(stakes, ";"),
+		strings.Join(reputations, ";"),
+		strings.Join(mempoolSizes, ";"),
+		strings.Join(chainLengths, ";"),
+		strings.Join(targeted, ";"),
+		strings.Join(pending, ";"),
+		strings.Join(inclusionSlots, ";"),
+	})
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func (w *csvMetricsWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// addresses lists the addresses of validators
+func addresses(validators []*Validator) []string {
+	list := make([]string, len(validators))
+	for i, validator := range validators {
+		list[i] = validator.Address
+	}
+	return list
+}
+
+// finishSlotMetrics completes currentSlot with the state of the network
+func (sim *Simulation) finishSlotMetrics() SlotMetrics {
+	metrics := sim.currentSlot
+	metrics.Time = sim.clock.Now().Format(time.RFC3339)
+	metrics.Forked = sim.forked
+	metrics.TotalBlocks = len(sim.CertifiedBlockchain)
+	for _, block := range sim.CertifiedBlockchain {
+		if block.IsMalicious {
+			metrics.MaliciousBlocks++
+		}
+	}
+	metrics.FinalizedBlocks = len(sim.FinalizedBlockchain())
+	if sim.finality != nil {
+		metrics.JustifiedEpoch = sim.finality.lastJustified.epoch
+		metrics.FinalizedEpoch = sim.finality.lastFinalized.epoch
+	}
+
+	metrics.Validators = make([]ValidatorMetrics, len(sim.validators))
+	for i, validator := range sim.validators {
+		validator.transactionPoolLock.Lock()
+		mempoolSize := len(validator.unconfirmedTransactions)
+		validator.transactionPoolLock.Unlock()
+		metrics.Validators[i] = ValidatorMetrics{
+			Address:     validator.Address,
+			IsMalicious: validator.IsMalicious,
+			Stake:       validator.Stake,
+			Reputation:  validator.reputation,
+			MempoolSize: mempoolSize,
+			ChainLength: len(validator.Blockchain),
+		}
+	}
+	metrics.ChainHeads = len(sim.chainHeads())
+
+	metrics.Users = make([]UserMetrics, 0, len(sim.users))
+	for _, user := range sim.sortedUsers() {
+		userMetrics := UserMetrics{Name: user.Name, Targeted: user.targeted, Sent: user.sent, Included: user.included}
+		if user.included > 0 {
+			userMetrics.MeanInclusionSlots = float64(user.inclusionSlots) / float64(user.included)
+		}
+		metrics.Users = append(metrics.Users, userMetrics)
+	}
+	return metrics
+}
+
+// writeSlotMetrics records the slot that just ended. A failed write is kept
+// for flushMetrics to report, since the event loop has no caller to return it to.
+func (sim *Simulation) writeSlotMetrics() {
+	if sim.metricsWriter == nil || sim.metricsErr != nil {
+		return
+	}
+	sim.metricsErr = sim.metricsWriter.Write(sim.finishSlotMetrics())
+	if sim.metricsErr != nil {
+		fmt.Fprintf(sim.out, "writing metrics: %s\n", sim.metricsErr)
+	}
+}
+
+// flushMetrics flushes metricsWriter and reports the first error writing to it
+func (sim *Simulation) flushMetrics() error {
+	if sim.metricsWriter == nil {
+		return nil
+	}
+	err := sim.metricsWriter.Flush()
+	if sim.metricsErr != nil {
+		return sim.metricsErr
+	}
+	return err
+}
@@ -0,0 +1,110 @@
+package pos
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// testSlotMetrics are two slots of a network with one honest and one
+// malicious validator
+func testSlotMetrics() []SlotMetrics {
+	validators := []ValidatorMetrics{
+		{Address: "a", IsMalicious: true, Stake: 500, Reputation: 5, MempoolSize: 2, ChainLength: 3},
+		{Address: "b", Stake: 312.5, Reputation: 4.5, ChainLength: 2},
+	}
+	return []SlotMetrics{
+		{Slot: 1, Proposer: "a", Committee: []string{"a", "b"}, ValidVotes: 2, TotalBlocks: 1, MaliciousBlocks: 1, Validators: validators},
+		{Slot: 2, Proposer: "b", Committee: []string{"b"}, InvalidVotes: 1, Forked: true, ChainHeads: 2, TotalBlocks: 1, MaliciousBlocks: 1, Validators: validators},
+	}
+}
+
+// writeMetrics writes slots in format and returns the output
+func writeMetrics(t *testing.T, format string, slots []SlotMetrics) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	metrics, err := NewMetricsWriter(&buf, format)
+	if err != nil {
+		t.Fatalf("NewMetricsWriter: %v", err)
+	}
+	for _, slot := range slots {
+		if err := metrics.Write(slot); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := metrics.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestCSVMetricsHaveOneRowPerSlot(t *testing.T) {
+	slots := testSlotMetrics()
+	rows, err := csv.NewReader(bytes.NewReader(writeMetrics(t, "csv", slots))).ReadAll()
+	if err != nil {
+		t.Fatalf("reading the csv: %v", err)
+	}
+	if len(rows) != len(slots)+1 {
+		t.Fatalf("%d rows, want a header and %d slots", len(rows), len(slots))
+	}
+	column := make(map[string]int)
+	for i, name := range rows[0] {
+		column[name] = i
+	}
+	for name, want := range map[string]string{
+		"slot":                 "1",
+		"proposer":             "a",
+		"committee":            "a;b",
+		"valid_votes":          "2",
+		"forked":               "false",
+		"malicious_validators": "a",
+		"stakes":               "a=500;b=312.5",
+		"mempool_sizes":        "a=2;b=0",
+	} {
+		i, ok := column[name]
+		if !ok {
+			t.Errorf("no %s column", name)
+			continue
+		}
+		if got := rows[1][i]; got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if got := rows[2][column["forked"]]; got != "true" {
+		t.Errorf("forked of slot 2 = %q, want true", got)
+	}
+}
+
+func TestJSONLMetricsHaveOneLinePerSlot(t *testing.T) {
+	slots := testSlotMetrics()
+	scanner := bufio.NewScanner(bytes.NewReader(writeMetrics(t, "jsonl", slots)))
+	lines := 0
+	for scanner.Scan() {
+		var metrics SlotMetrics
+		if err := json.Unmarshal(scanner.Bytes(), &metrics); err != nil {
+			t.Fatalf("line %d: %v", lines+1, err)
+		}
+		if lines < len(slots) && !reflect.DeepEqual(metrics, slots[lines]) {
+			t.Errorf("line %d = %+v, want %+v", lines+1, metrics, slots[lines])
+		}
+		lines++
+	}
+	if lines != len(slots) {
+		t.Errorf("%d lines, want %d slots", lines, len(slots))
+	}
+}
+
+func TestMetricsFormat(t *testing.T) {
+	for path, want := range map[string]string{"run.csv": "csv", "run.CSV": "csv", "run.jsonl": "jsonl", "run": "jsonl"} {
+		if got := MetricsFormat(path); got != want {
+			t.Errorf("MetricsFormat(%q) = %q, want %q", path, got, want)
+		}
+	}
+	if _, err := NewMetricsWriter(io.Discard, "xml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
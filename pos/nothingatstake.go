@@ -0,0 +1,105 @@
+package pos
+
+import "fmt"
+
+// nothingAtStake has malicious validators play as rational ones for whom
+// signing costs nothing. A malicious proposer signs a second block on the
+// shortest branch besides its own, or forks its own branch if the chain is
+// not forked, and malicious voters vote for every block extending the chain
+// of some validator, where honest ones only judge blocks against one view of
+// the chain. Validators keep the blocks extending their own chain, so every
+// branch grows, and longest chain consensus is delayed while the longest
+// branches are equally long. Under slashEquivocation signing two blocks or
+// voting for both is slashed.
+type nothingAtStake struct {
+	baseAttack
+	proposal *Proposal
+}
+
+func (n *nothingAtStake) OnProposerSelected(sim *Simulation, slot *Slot) {
+	n.proposal = nil
+}
+
+func (n *nothingAtStake) OnBlockGenerated(sim *Simulation, slot *Slot) error {
+	if !slot.Proposer.IsMalicious || len(slot.Proposals) > 1 {
+		return nil
+	}
+	tip := slot.Proposer.Blockchain[len(slot.Proposer.Blockchain)-1]
+	branch := tip
+	for _, head := range sim.chainHeads() {
+		if head.Hash != tip.Hash && (branch.Hash == tip.Hash || head.Index < branch.Index) {
+			branch = head
+		}
+	}
+	//a sibling of the proposer's block carries other transactions
+	var skip map[int]bool
+	if branch.Hash == tip.Hash {
+		skip = make(map[int]bool)
+		for _, transaction := range slot.Proposals[0].Block.Transactions {
+			skip[transaction.ID] = true
+		}
+	}
+	block, err := sim.generateBlockOn(slot.Proposer, branch, skip)
+	if err != nil {
+		return nil
+	}
+	fmt.Fprintf(sim.out, "Validator %s also signs block %d on another branch\n", slot.Proposer.Address[:3], block.Index)
+	n.proposal = &Proposal{Block: block, Proposer: slot.Proposer}
+	slot.Proposals = append(slot.Proposals, n.proposal)
+	sim.security.NothingAtStake.NothingAtStakeBlocks++
+	return nil
+}
+
+func (n *nothingAtStake) OnVote(sim *Simulation, validator *Validator, block Block, valid bool) bool {
+	if !validator.IsMalicious || valid {
+		return valid
+	}
+	for _, head := range sim.chainHeads() {
+		if head.Hash == block.PrevHash && head.Index+1 == block.Index {
+			return calculateBlockHash(block) == block.Hash && sim.isBlockSigned(block)
+		}
+	}
+	return false
+}
+
+// OnBroadcast splits the validators between the proposer's block and its
+// sibling when the proposer forked its own branch and both were accepted
+func (n *nothingAtStake) OnBroadcast(sim *Simulation, slot *Slot) {
+	if n.proposal == nil || len(slot.Proposals) < 2 || slot.Proposals[1] != n.proposal {
+		return
+	}
+	if n.proposal.Accepted {
+		sim.security.NothingAtStake.NothingAtStakeAccepted++
+	}
+	own := slot.Proposals[0]
+	if own.Forced || !own.Accepted || !n.proposal.Accepted || n.proposal.Block.PrevHash != own.Block.PrevHash {
+		return
+	}
+	own.Recipients = []*Validator{slot.Proposer}
+	n.proposal.Recipients = nil
+	for _, validator := range sim.validators {
+		switch {
+		case validator == slot.Proposer:
+		case sim.rng.Intn(2) == 0:
+			own.Recipients = append(own.Recipients, validator)
+		default:
+			n.proposal.Recipients = append(n.proposal.Recipients, validator)
+		}
+	}
+}
+
+// OnConsensus delays consensus while another chain is as long as the chosen
+// one, as the longest chain rule cannot break the tie
+func (n *nothingAtStake) OnConsensus(sim *Simulation, chosen *Validator) *Validator {
+	if chosen == nil || sim.ghost != nil {
+		return chosen
+	}
+	head := chosen.Blockchain[len(chosen.Blockchain)-1]
+	for _, validator := range sim.validators {
+		other := validator.Blockchain[len(validator.Blockchain)-1]
+		if other.Hash != head.Hash && len(validator.Blockchain) == len(chosen.Blockchain) {
+			return nil
+		}
+	}
+	return chosen
+}
@@ -0,0 +1,17 @@
+package pos
+
+import "testing"
+
+func TestEquivocatorsAreSlashed(t *testing.T) {
+	s := testScenario(42)
+	s.NumSlots = 60
+	s.Attack = "nothing_at_stake"
+	s.SlashEquivocation = true
+	r := simulate(t, s).Evaluation.Security
+	if r.NothingAtStake.NothingAtStakeBlocks == 0 {
+		t.Fatal("no block was signed on a second branch")
+	}
+	if r.SlashedEquivocators == 0 {
+		t.Errorf("%d blocks signed on a second branch, no equivocator slashed", r.NothingAtStake.NothingAtStakeBlocks)
+	}
+}
@@ -0,0 +1,167 @@
+package pos
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ouroborosProtocol elects slot leaders in the style of Ouroboros. Time is
+// split into epochs of epochLength slots. At the start of every epoch the
+// stake of every validator is snapshotted and the leader schedule of the
+// whole epoch is derived from the snapshot and the epoch's randomness: in
+// every slot each validator is a leader with chance 1-(1-f)^share, where f is
+// the active slot coefficient and share its share of the snapshotted stake,
+// so slots may have no leader or several. The randomness of the next epoch
+// hashes the blocks added to the chain in the first 2/3 of the epoch, which
+// leaders can grind on by choosing what to add. There is no committee vote
+// beyond every validator checking the block, and forks are left to longest
+// chain consensus.
+type ouroborosProtocol struct {
+	baseProtocol
+	// Epoch in progress, and the randomness its schedule was derived from
+	epoch int
+	nonce []byte
+	// Randomness of the next epoch, once 2/3 of this one has passed
+	nextNonce []byte
+	// Stake of every validator when the epoch started, by address
+	snapshot map[string]float64
+	// Leaders of every slot of the epoch, and of the slot in progress
+	schedule [][]*Validator
+	leaders  []*Validator
+	// Height of the chain when the epoch started
+	epochStart int
+}
+
+func newOuroborosProtocol() *ouroborosProtocol {
+	return &ouroborosProtocol{epoch: -1}
+}
+
+// startEpoch snapshots the stake and derives the leader schedule of epoch
+func (p *ouroborosProtocol) startEpoch(sim *Simulation, epoch int) {
+	switch {
+	case p.nonce == nil:
+		nonce := sha256.Sum256([]byte(sim.CertifiedBlockchain[0].Hash))
+		p.nonce = nonce[:]
+	case p.nextNonce != nil:
+		p.nonce = p.nextNonce
+	default:
+		p.nonce = p.epochNonce(sim)
+	}
+	p.nextNonce = nil
+	p.epoch = epoch
+	chain := p.ForkChoice(sim).Blockchain
+	p.epochStart = chain[len(chain)-1].Index
+
+	p.snapshot = make(map[string]float64)
+	total := 0.0
+	for _, validator := range sim.validators {
+		p.snapshot[validator.Address] = validator.Stake
+		total += validator.Stake
+	}
+
+	epochLength := sim.scenario.EpochLength
+	p.schedule = make([][]*Validator, epochLength)
+	for i := range p.schedule {
+		slot := epoch*epochLength + i
+		draws := make(map[*Validator][32]byte)
+		for _, validator := range sim.validators {
+			share := p.snapshot[validator.Address] / total
+			draw := leaderDraw(p.nonce, slot, validator.Address)
+			if float64(binary.BigEndian.Uint64(draw[:8]))/math.Pow(2, 64) < 1-math.Pow(1-sim.scenario.ActiveSlotCoefficient, share) {
+				p.schedule[i] = append(p.schedule[i], validator)
+				draws[validator] = draw
+			}
+		}
+		//the leader with the lowest draw proposes first
+		sort.Slice(p.schedule[i], func(a, b int) bool {
+			drawA, drawB := draws[p.schedule[i][a]], draws[p.schedule[i][b]]
+			return bytes.Compare(drawA[:], drawB[:]) < 0
+		})
+	}
+	fmt.Fprintf(sim.out, "Epoch %d leader schedule: %s\n", epoch, p.scheduleString())
+}
+
+// leaderDraw is the number a validator draws to lead slot, which anyone
+// knowing the epoch's randomness can compute
+func leaderDraw(nonce []byte, slot int, address string) [32]byte {
+	input := binary.BigEndian.AppendUint64(append([]byte{}, nonce...), uint64(slot))
+	return sha256.Sum256(append(input, address...))
+}
+
+// epochNonce hashes the randomness of the epoch with the blocks added to the
+// chain since it started
+func (p *ouroborosProtocol) epochNonce(sim *Simulation) []byte {
+	input := binary.BigEndian.AppendUint64(append([]byte{}, p.nonce...), uint64(p.epoch+1))
+	for _, block := range p.ForkChoice(sim).Blockchain {
+		if block.Index > p.epochStart {
+			input = append(input, block.Hash...)
+		}
+	}
+	nonce := sha256.Sum256(input)
+	return nonce[:]
+}
+
+// scheduleString shows how many leaders every slot of the epoch has
+func (p *ouroborosProtocol) scheduleString() string {
+	var b bytes.Buffer
+	for _, leaders := range p.schedule {
+		fmt.Fprintf(&b, "%d", len(leaders))
+	}
+	return b.String()
+}
+
+// SelectCommittee moves to the next epoch at its boundary, fixes the next
+// epoch's randomness once 2/3 of this one has passed, and has every validator
+// check the block
+func (p *ouroborosProtocol) SelectCommittee(sim *Simulation) []*Validator {
+	epochLength := sim.scenario.EpochLength
+	if epoch := sim.roundCount / epochLength; epoch != p.epoch {
+		p.startEpoch(sim, epoch)
+	}
+	if sim.roundCount%epochLength == (2*epochLength+2)/3 {
+		p.nextNonce = p.epochNonce(sim)
+	}
+
+	p.leaders = p.schedule[sim.roundCount%epochLength]
+	sim.security.Leaders.recordLeaders(p.leaders)
+	sim.currentSlot.Leaders = addresses(p.leaders)
+	committee := make([]*Validator, len(sim.validators))
+	copy(committee, sim.validators)
+	return committee
+}
+
+// SelectProposer returns the first leader of the slot, or nil if the slot is
+// empty
+func (p *ouroborosProtocol) SelectProposer(sim *Simulation, committee []*Validator) *Validator {
+	if len(p.leaders) == 0 {
+		fmt.Fprintln(sim.out, "Empty slot")
+		return nil
+	}
+	if len(p.leaders) > 1 {
+		fmt.Fprintf(sim.out, "%d slot leaders\n", len(p.leaders))
+	}
+	return p.leaders[0]
+}
+
+// OtherLeaders returns the leaders of the slot besides its proposer
+func (p *ouroborosProtocol) OtherLeaders(sim *Simulation) []*Validator {
+	if len(p.leaders) < 2 {
+		return nil
+	}
+	return p.leaders[1:]
+}
+
+// RewardProposer leaves leaders with their transaction rewards only
+func (p *ouroborosProtocol) RewardProposer(sim *Simulation, proposer *Validator) {}
+
+// PunishProposer does nothing, Ouroboros has no slashing
+func (p *ouroborosProtocol) PunishProposer(sim *Simulation, proposer *Validator) {}
+
+func (p *ouroborosProtocol) SettleVotes(sim *Simulation, committee []*Validator, votes Votes, accepted bool) {
+}
+
+func (p *ouroborosProtocol) PunishForkProposer(sim *Simulation, proposer *Validator) {}
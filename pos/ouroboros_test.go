@@ -0,0 +1,70 @@
+package pos
+
+import (
+	"io"
+	"testing"
+)
+
+func TestTransactionsSettleOnce(t *testing.T) {
+	s := testScenario(1)
+	s.BlockchainType = "ouroboros"
+	sim := newTestSimulation(t, s)
+	sender, err := sim.newUser(io.Discard, "sender", 100)
+	if err != nil {
+		t.Fatalf("newUser: %v", err)
+	}
+	receiver, err := sim.newUser(io.Discard, "receiver", 100)
+	if err != nil {
+		t.Fatalf("newUser: %v", err)
+	}
+	first := sim.newValidator(io.Discard, 100, false)
+	second := sim.newValidator(io.Discard, 100, false)
+	transaction := generateTransaction(1, sender, receiver, 10, 1)
+
+	//two leaders of one slot both put the transaction in their block
+	genesis := sim.CertifiedBlockchain[0]
+	blocks := make(map[*Validator]Block)
+	for _, leader := range []*Validator{first, second} {
+		block := Block{Index: 1, PrevHash: genesis.Hash, Validator: leader.Address, Transactions: []Transaction{transaction}}
+		block.Hash = calculateBlockHash(block)
+		blocks[leader] = block
+		sim.acceptBlock(&Proposal{Block: block, Proposer: leader, Accepted: true})
+	}
+	if sender.Balance != 89 || receiver.Balance != 110 {
+		t.Fatalf("balances after both blocks: sender %v, receiver %v, want 89 and 110", sender.Balance, receiver.Balance)
+	}
+
+	//consensus keeps the block of the second leader, which earns the reward
+	firstStake, secondStake := first.Stake, second.Stake
+	sim.CertifiedBlockchain = []Block{genesis, blocks[second]}
+	sim.revertOrphanedTransactions()
+	if first.Stake != firstStake-1 || second.Stake != secondStake+1 {
+		t.Errorf("stakes after consensus: %v and %v, want %v and %v", first.Stake, second.Stake, firstStake-1, secondStake+1)
+	}
+	if sender.Balance != 89 || receiver.Balance != 110 {
+		t.Errorf("balances after consensus: sender %v, receiver %v, want 89 and 110", sender.Balance, receiver.Balance)
+	}
+
+	//then drops it for a chain without the transaction
+	sim.CertifiedBlockchain = []Block{genesis}
+	sim.revertOrphanedTransactions()
+	if second.Stake != secondStake {
+		t.Errorf("stake after the block was dropped: %v, want %v", second.Stake, secondStake)
+	}
+	if sender.Balance != 100 || receiver.Balance != 100 {
+		t.Errorf("balances after the block was dropped: sender %v, receiver %v, want 100 and 100", sender.Balance, receiver.Balance)
+	}
+}
+
+func TestCharacteristicStringHasALetterPerSlot(t *testing.T) {
+	s := testScenario(1)
+	s.BlockchainType = "ouroboros"
+	leaders := simulate(t, s).Evaluation.Security.Leaders
+	if leaders == nil {
+		t.Fatal("no slot leader metrics reported")
+	}
+	counted := leaders.EmptySlots + leaders.UniqueHonestSlots + leaders.MultiHonestSlots + leaders.AdversarialSlots
+	if len(leaders.CharacteristicString) != counted || counted != s.NumSlots {
+		t.Errorf("characteristic string %q over %d counted slots, want %d", leaders.CharacteristicString, counted, s.NumSlots)
+	}
+}
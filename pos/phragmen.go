@@ -0,0 +1,129 @@
+package pos
+
+import (
+	"fmt"
+	"strings"
+)
+
+// nominator backs the validators it nominates with its budget
+type nominator struct {
+	budget  float64
+	targets []string
+}
+
+// nominators lists the users with tokens locked behind their nominations,
+// followed by every validator backing itself with its own stake
+func (sim *Simulation) nominators() []nominator {
+	nominators := make([]nominator, 0, len(sim.users)+len(sim.validators))
+	for _, user := range sim.sortedUsers() {
+		if user.locked > 0 && len(user.nominations) > 0 {
+			nominators = append(nominators, nominator{budget: user.locked, targets: user.nominations})
+		}
+	}
+	for _, validator := range sim.validators {
+		nominators = append(nominators, nominator{budget: validator.Stake, targets: []string{validator.Address}})
+	}
+	return nominators
+}
+
+// seqPhragmen elects seats of candidates by sequential Phragmén and returns
+// them with the stake backing each, by address. Every round elects the
+// candidate with the lowest score, one plus the load its nominators already
+// carry weighted by their budgets over the budget nominating it, and raises
+// the load of its nominators to that score. Every nominator's budget is then
+// split among its elected nominees in proportion to the load each added, so
+// a minority concentrating its stake on a few candidates wins no more seats
+// than its share of the stake backs.
+func seqPhragmen(candidates []*Validator, nominators []nominator, seats int) ([]*Validator, map[string]float64) {
+	approval := make(map[string]float64)
+	for _, n := range nominators {
+		for _, target := range n.targets {
+			approval[target] += n.budget
+		}
+	}
+
+	load := make([]float64, len(nominators))
+	added := make([]map[string]float64, len(nominators))
+	for i := range added {
+		added[i] = make(map[string]float64)
+	}
+	elected := make([]*Validator, 0, seats)
+	isElected := make(map[string]bool)
+	for len(elected) < seats {
+		var best *Validator
+		bestScore := 0.0
+		for _, candidate := range candidates {
+			if isElected[candidate.Address] || approval[candidate.Address] <= 0 {
+				continue
+			}
+			score := 1.0
+			for i, n := range nominators {
+				if nominates(n, candidate.Address) {
+					score += n.budget * load[i]
+				}
+			}
+			score /= approval[candidate.Address]
+			if best == nil || score < bestScore {
+				best, bestScore = candidate, score
+			}
+		}
+		if best == nil {
+			break
+		}
+		elected = append(elected, best)
+		isElected[best.Address] = true
+		for i, n := range nominators {
+			if nominates(n, best.Address) {
+				added[i][best.Address] = bestScore - load[i]
+				load[i] = bestScore
+			}
+		}
+	}
+
+	backing := make(map[string]float64)
+	for i, n := range nominators {
+		if load[i] == 0 {
+			continue
+		}
+		for _, target := range n.targets {
+			if isElected[target] {
+				backing[target] += n.budget * added[i][target] / load[i]
+			}
+		}
+	}
+
+	//fill seats nobody nominated for in the order validators joined
+	for _, candidate := range candidates {
+		if len(elected) == seats {
+			break
+		}
+		if !isElected[candidate.Address] {
+			elected = append(elected, candidate)
+			isElected[candidate.Address] = true
+		}
+	}
+	return elected, backing
+}
+
+// nominates reports whether n nominates the validator with address
+func nominates(n nominator, address string) bool {
+	for _, target := range n.targets {
+		if target == address {
+			return true
+		}
+	}
+	return false
+}
+
+// electPhragmen elects delegateSize validators by sequential Phragmén over
+// the nominations of users and the stake of validators
+func (sim *Simulation) electPhragmen(delegateSize int) []*Validator {
+	delegates, backing := seqPhragmen(sim.validators, sim.nominators(), delegateSize)
+	names := make([]string, len(delegates))
+	for i, delegate := range delegates {
+		names[i] = fmt.Sprintf("%s (%.2f)", delegate.Address[:3], backing[delegate.Address])
+	}
+	fmt.Fprintf(sim.out, "Validators elected: %s\n", strings.Join(names, ", "))
+	sim.recordElection(delegates, backing)
+	return delegates
+}
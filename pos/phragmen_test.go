@@ -0,0 +1,30 @@
+package pos
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPhragmenGivesAMinorityItsShareOfSeats(t *testing.T) {
+	candidates := []*Validator{{Address: "a"}, {Address: "b"}, {Address: "c"}, {Address: "d"}}
+	nominators := []nominator{
+		{budget: 60, targets: []string{"a", "b", "c"}},
+		{budget: 40, targets: []string{"d"}},
+	}
+	elected, backing := seqPhragmen(candidates, nominators, 2)
+	addresses := make([]string, len(elected))
+	for i, validator := range elected {
+		addresses[i] = validator.Address
+	}
+	if !reflect.DeepEqual(addresses, []string{"a", "d"}) {
+		t.Fatalf("elected %v, want a and d", addresses)
+	}
+	if backing["a"] != 60 || backing["d"] != 40 {
+		t.Errorf("backing %v, want a 60 and d 40", backing)
+	}
+
+	elected, _ = seqPhragmen(candidates, nominators, 6)
+	if len(elected) != len(candidates) {
+		t.Errorf("%d elected for 6 seats among %d candidates", len(elected), len(candidates))
+	}
+}
@@ -0,0 +1,24 @@
+package pos
+
+import (
+	"math/rand"
+	"strconv"
+)
+
+// randomAddress returns a fresh address for a validator or user
+func (sim *Simulation) randomAddress() string {
+	return calculateHash(strconv.FormatUint(sim.rng.Uint64(), 16))
+}
+
+// sampleSource lets gonum samplers draw from a run's randomness
+type sampleSource struct {
+	rng *rand.Rand
+}
+
+func (s sampleSource) Uint64() uint64 {
+	return s.rng.Uint64()
+}
+
+func (s sampleSource) Seed(seed uint64) {
+	s.rng.Seed(int64(seed))
+}
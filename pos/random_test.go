@@ -0,0 +1,24 @@
+package pos
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSeedReproducesTheDraws(t *testing.T) {
+	draw := func(seed int64) []string {
+		sim := newTestSimulation(t, testScenario(seed))
+		addresses := make([]string, 3)
+		for i := range addresses {
+			addresses[i] = sim.randomAddress()
+		}
+		return addresses
+	}
+	first := draw(7)
+	if second := draw(7); !reflect.DeepEqual(first, second) {
+		t.Errorf("seed 7 drew %v, then %v", first, second)
+	}
+	if other := draw(8); reflect.DeepEqual(first, other) {
+		t.Errorf("seeds 7 and 8 drew the same addresses %v", first)
+	}
+}
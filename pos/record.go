@@ -0,0 +1,32 @@
+package pos
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// RunRecord is everything needed to replay a run and check its outcome
+type RunRecord struct {
+	Scenario   Scenario   `json:"scenario"`
+	Evaluation Evaluation `json:"evaluation"`
+}
+
+// WriteRunRecord saves record as JSON to path
+func WriteRunRecord(path string, record RunRecord) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// ReadRunRecord loads a record saved by WriteRunRecord
+func ReadRunRecord(path string) (RunRecord, error) {
+	record := RunRecord{Scenario: DefaultScenario()}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return record, err
+	}
+	err = json.Unmarshal(data, &record)
+	return record, err
+}
@@ -0,0 +1,682 @@
+package pos
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// SecurityReport measures how well the attack of a run succeeded. Metrics of
+// an attack, protocol or finality gadget are only reported when it was active
+type SecurityReport struct {
+	Attack         string `json:"attack"`
+	BlockchainType string `json:"blockchainType"`
+	Finality       string `json:"finality"`
+	ForkChoice     string `json:"forkChoice"`
+	Slots          int    `json:"slots"`
+
+	// Blocks validators dropped from their chain when longest chain consensus
+	// replaced it
+	Reorgs          int `json:"reorgs"`
+	MaxReorgDepth   int `json:"maxReorgDepth"`
+	TotalReorgDepth int `json:"totalReorgDepth"`
+
+	// Consensus rounds delayed, e.g. because two forks were too close, and the
+	// longest run of them in rounds and in slots
+	ConsensusRounds            int  `json:"consensusRounds"`
+	DelayedConsensusRounds     int  `json:"delayedConsensusRounds"`
+	LongestConsensusDelay      int  `json:"longestConsensusDelay"`
+	LongestConsensusDelaySlots int  `json:"longestConsensusDelaySlots"`
+	ConsensusDelayedAtEndOfRun bool `json:"consensusDelayedAtEndOfRun"`
+
+	// Forks, times validators' chains split into several heads, how many of
+	// them consensus resolved and in how many slots
+	Forks                      int     `json:"forks"`
+	ResolvedForks              int     `json:"resolvedForks"`
+	TotalForkResolutionSlots   int     `json:"totalForkResolutionSlots"`
+	MeanForkResolutionSlots    float64 `json:"meanForkResolutionSlots"`
+	LongestForkResolutionSlots int     `json:"longestForkResolutionSlots"`
+	// Validators slashed under slashEquivocation for signing two blocks or
+	// voting for both in one slot
+	SlashedEquivocators int `json:"slashedEquivocators,omitempty"`
+
+	Partition      *PartitionReport      `json:"partition,omitempty"`
+	Dos            *DosReport            `json:"dos,omitempty"`
+	Leaders        *LeaderReport         `json:"leaders,omitempty"`
+	Casper         *CasperReport         `json:"casper,omitempty"`
+	Liveness       *LivenessReport       `json:"liveness,omitempty"`
+	Elections      *ElectionReport       `json:"elections,omitempty"`
+	VoteBuying     *VoteBuyingReport     `json:"voteBuying,omitempty"`
+	LongRange      *LongRangeReport      `json:"longRange,omitempty"`
+	NothingAtStake *NothingAtStakeReport `json:"nothingAtStake,omitempty"`
+	Selfish        *SelfishReport        `json:"selfish,omitempty"`
+	Censorship     *CensorshipReport     `json:"censorship,omitempty"`
+	DoubleSpend    *DoubleSpendReport    `json:"doubleSpend,omitempty"`
+
+	TotalBlocks         int     `json:"totalBlocks"`
+	MaliciousBlocks     int     `json:"maliciousBlocks"`
+	MaliciousBlockShare float64 `json:"maliciousBlockShare"`
+	// Transaction rewards of the blocks in the certified chain, and the share
+	// of them malicious proposers earned
+	TotalRewards         float64 `json:"totalRewards"`
+	MaliciousRewards     float64 `json:"maliciousRewards"`
+	MaliciousRewardShare float64 `json:"maliciousRewardShare"`
+
+	MaliciousValidators   int     `json:"maliciousValidators"`
+	MaliciousInitialStake float64 `json:"maliciousInitialStake"`
+	MaliciousFinalStake   float64 `json:"maliciousFinalStake"`
+	// Stake gained, or lost if negative, by malicious validators over the run
+	MaliciousStakeChange float64 `json:"maliciousStakeChange"`
+	// Fraction of all stake held by malicious validators at the start and end
+	MaliciousInitialStakeShare float64 `json:"maliciousInitialStakeShare"`
+	MaliciousFinalStakeShare   float64 `json:"maliciousFinalStakeShare"`
+}
+
+// PartitionReport counts the slots network_partition ended with the chain
+// forked, and the longest run of them
+type PartitionReport struct {
+	ForkedSlots        int `json:"forkedSlots"`
+	LongestForkedSlots int `json:"longestForkedSlots"`
+}
+
+// DosReport counts the validators targeted_dos knocked offline and how many
+// of them were proposing or on the committee, slots skipped with the proposer
+// offline and committees left with a malicious majority
+type DosReport struct {
+	DosTargets         int `json:"dosTargets"`
+	DosHits            int `json:"dosHits"`
+	DosSkippedSlots    int `json:"dosSkippedSlots"`
+	CapturedCommittees int `json:"capturedCommittees"`
+}
+
+// LeaderReport counts the ouroboros slots without a leader, with a single or
+// several honest leaders and with a malicious leader, and records every slot
+// in a row as _, h, H or A, the characteristic string forkable string
+// analysis works on
+type LeaderReport struct {
+	EmptySlots           int    `json:"emptySlots"`
+	UniqueHonestSlots    int    `json:"uniqueHonestSlots"`
+	MultiHonestSlots     int    `json:"multiHonestSlots"`
+	AdversarialSlots     int    `json:"adversarialSlots"`
+	CharacteristicString string `json:"characteristicString"`
+}
+
+// CasperReport counts the checkpoints casper_ffg justified and finalized,
+// blocks of the certified chain that are final, and attesters slashed for
+// voting twice in an epoch or surrounding their own vote
+type CasperReport struct {
+	JustifiedCheckpoints int `json:"justifiedCheckpoints"`
+	FinalizedCheckpoints int `json:"finalizedCheckpoints"`
+	FinalizedBlocks      int `json:"finalizedBlocks"`
+	DoubleVotes          int `json:"doubleVotes"`
+	SurroundVotes        int `json:"surroundVotes"`
+	SlashedAttesters     int `json:"slashedAttesters"`
+}
+
+// LivenessReport counts the tendermint rounds or hotstuff views that timed
+// out without a quorum and the longest run of them, and blocks hotstuff
+// certified
+type LivenessReport struct {
+	TimedOutViews        int `json:"timedOutViews"`
+	LongestTimeoutStreak int `json:"longestTimeoutStreak"`
+	CertifiedBlocks      int `json:"certifiedBlocks"`
+}
+
+// ElectionReport counts the dpos and npos vote transactions included in
+// blocks, delegate elections, seats malicious validators won over all
+// elections and elections they won most seats in, and records their share of
+// the tokens backing validators and the lowest backing of a delegate at the
+// last election
+type ElectionReport struct {
+	VoteTransactions        int     `json:"voteTransactions"`
+	DelegateElections       int     `json:"delegateElections"`
+	CartelSeats             int     `json:"cartelSeats"`
+	CartelMajorityElections int     `json:"cartelMajorityElections"`
+	CartelVoteShare         float64 `json:"cartelVoteShare"`
+	MinBacking              float64 `json:"minBacking"`
+}
+
+// VoteBuyingReport counts the votes vote_buying bought and the bribes paid
+// for them
+type VoteBuyingReport struct {
+	VotesBought int     `json:"votesBought"`
+	BribesPaid  float64 `json:"bribesPaid"`
+}
+
+// LongRangeReport counts the validators joining after the start that
+// long_range offered a forged chain and that bootstrapped on it, and the keys
+// the attacker held at the last attempt and how many of them were retired
+// validators'
+type LongRangeReport struct {
+	LongRangeJoiners  int `json:"longRangeJoiners"`
+	LongRangeCaptured int `json:"longRangeCaptured"`
+	LongRangeKeys     int `json:"longRangeKeys"`
+	RetiredKeys       int `json:"retiredKeys"`
+}
+
+// NothingAtStakeReport counts the blocks nothing_at_stake proposers signed on
+// a second branch and how many of them were accepted
+type NothingAtStakeReport struct {
+	NothingAtStakeBlocks   int `json:"nothingAtStakeBlocks"`
+	NothingAtStakeAccepted int `json:"nothingAtStakeAccepted"`
+}
+
+// SelfishReport counts the blocks selfish_proposing withheld, private chains
+// released and abandoned, and honest blocks the released chains orphaned
+type SelfishReport struct {
+	WithheldBlocks         int `json:"withheldBlocks"`
+	PrivateChainsReleased  int `json:"privateChainsReleased"`
+	PrivateChainsAbandoned int `json:"privateChainsAbandoned"`
+	OrphanedBlocks         int `json:"orphanedBlocks"`
+}
+
+// CensorshipReport counts the times censorship left a transaction of a
+// targeted user out of a block, the transactions left out, how many of them
+// were included later and the time slots they waited. It compares the
+// transactions of the targeted users and of the others included in accepted
+// blocks and the time slots from being sent to inclusion, and counts the
+// transactions of targeted users still waiting at the end of the run
+type CensorshipReport struct {
+	CensorshipExclusions     int     `json:"censorshipExclusions"`
+	CensoredTransactions     int     `json:"censoredTransactions"`
+	CensoredIncluded         int     `json:"censoredIncluded"`
+	TotalCensoredWaitSlots   int     `json:"totalCensoredWaitSlots"`
+	MeanCensoredWaitSlots    float64 `json:"meanCensoredWaitSlots"`
+	LongestCensoredWaitSlots int     `json:"longestCensoredWaitSlots"`
+
+	TargetedIncluded            int     `json:"targetedIncluded"`
+	TotalTargetedInclusionSlots int     `json:"totalTargetedInclusionSlots"`
+	MeanTargetedInclusionSlots  float64 `json:"meanTargetedInclusionSlots"`
+	MaxTargetedInclusionSlots   int     `json:"maxTargetedInclusionSlots"`
+	OtherIncluded               int     `json:"otherIncluded"`
+	TotalOtherInclusionSlots    int     `json:"totalOtherInclusionSlots"`
+	MeanOtherInclusionSlots     float64 `json:"meanOtherInclusionSlots"`
+	MaxOtherInclusionSlots      int     `json:"maxOtherInclusionSlots"`
+	PendingTargeted             int     `json:"pendingTargeted"`
+}
+
+// DoubleSpendReport counts the forks double_spend spent the same funds on
+// both branches of, how many of them confirmed both transactions before
+// consensus resolved the fork and the amount spent twice, and which
+// transaction the certified chain kept
+type DoubleSpendReport struct {
+	DoubleSpends               int     `json:"doubleSpends"`
+	DoubleSpendsBothConfirmed  int     `json:"doubleSpendsBothConfirmed"`
+	DoubleSpentAmount          float64 `json:"doubleSpentAmount"`
+	DoubleSpendsFirstSurvived  int     `json:"doubleSpendsFirstSurvived"`
+	DoubleSpendsSecondSurvived int     `json:"doubleSpendsSecondSurvived"`
+	DoubleSpendsLost           int     `json:"doubleSpendsLost"`
+}
+
+// newSecurityReport sets up the metrics of the attack, protocol and finality
+// gadget of s
+func newSecurityReport(s Scenario) SecurityReport {
+	var r SecurityReport
+	if hasAttack(s.Attack, "network_partition") {
+		r.Partition = &PartitionReport{}
+	}
+	if hasAttack(s.Attack, "targeted_dos") {
+		r.Dos = &DosReport{}
+	}
+	if s.BlockchainType == "ouroboros" {
+		r.Leaders = &LeaderReport{}
+	}
+	if s.Finality == "casper_ffg" {
+		r.Casper = &CasperReport{}
+	}
+	if s.BlockchainType == "tendermint" || s.BlockchainType == "hotstuff" {
+		r.Liveness = &LivenessReport{}
+	}
+	if s.BlockchainType == "dpos" || s.BlockchainType == "npos" {
+		r.Elections = &ElectionReport{}
+	}
+	if hasAttack(s.Attack, "vote_buying") {
+		r.VoteBuying = &VoteBuyingReport{}
+	}
+	if hasAttack(s.Attack, "long_range") {
+		r.LongRange = &LongRangeReport{}
+	}
+	if hasAttack(s.Attack, "nothing_at_stake") {
+		r.NothingAtStake = &NothingAtStakeReport{}
+	}
+	if hasAttack(s.Attack, "selfish_proposing") {
+		r.Selfish = &SelfishReport{}
+	}
+	if hasAttack(s.Attack, "censorship") {
+		r.Censorship = &CensorshipReport{}
+	}
+	if hasAttack(s.Attack, "double_spend") {
+		r.DoubleSpend = &DoubleSpendReport{}
+	}
+	return r
+}
+
+// recordSlotSecurity counts the slot that just ended
+func (sim *Simulation) recordSlotSecurity() {
+	sim.security.Slots++
+	if p := sim.security.Partition; p != nil && sim.forked {
+		p.ForkedSlots++
+		sim.forkedStreak++
+		if sim.forkedStreak > p.LongestForkedSlots {
+			p.LongestForkedSlots = sim.forkedStreak
+		}
+	} else {
+		sim.forkedStreak = 0
+	}
+
+	if len(sim.chainHeads()) > 1 {
+		if sim.splitStreak == 0 {
+			sim.security.Forks++
+		}
+		sim.splitStreak++
+	} else if sim.splitStreak > 0 {
+		sim.security.ResolvedForks++
+		sim.security.TotalForkResolutionSlots += sim.splitStreak
+		if sim.splitStreak > sim.security.LongestForkResolutionSlots {
+			sim.security.LongestForkResolutionSlots = sim.splitStreak
+		}
+		sim.splitStreak = 0
+	}
+}
+
+// recordInclusion returns the time slots transaction waited for an accepted
+// block to include it and counts them for its sender. Transactions no user
+// sent, like the forged ones of long_range, are not timed.
+func (sim *Simulation) recordInclusion(transaction Transaction) int {
+	sent, ok := sim.sentAt[transaction.ID]
+	if !ok {
+		return 0
+	}
+	wait := sim.roundCount + 1 - sent
+	transaction.Sender.included++
+	transaction.Sender.inclusionSlots += wait
+	return wait
+}
+
+// revertInclusion takes back the inclusion of a transaction whose block the
+// certified chain dropped, so it is timed again once another block includes it
+func (sim *Simulation) revertInclusion(applied appliedTransaction) {
+	if _, ok := sim.sentAt[applied.transaction.ID]; !ok {
+		return
+	}
+	applied.transaction.Sender.included--
+	applied.transaction.Sender.inclusionSlots -= applied.wait
+}
+
+// recordInclusion counts a transaction of a targeted user or another one
+// that waited wait time slots to be settled, and whether it was censored
+func (r *CensorshipReport) recordInclusion(wait int, targeted bool, censored bool) {
+	if targeted {
+		r.TargetedIncluded++
+		r.TotalTargetedInclusionSlots += wait
+		if wait > r.MaxTargetedInclusionSlots {
+			r.MaxTargetedInclusionSlots = wait
+		}
+	} else {
+		r.OtherIncluded++
+		r.TotalOtherInclusionSlots += wait
+		if wait > r.MaxOtherInclusionSlots {
+			r.MaxOtherInclusionSlots = wait
+		}
+	}
+	if censored {
+		r.CensoredIncluded++
+		r.TotalCensoredWaitSlots += wait
+		if wait > r.LongestCensoredWaitSlots {
+			r.LongestCensoredWaitSlots = wait
+		}
+	}
+}
+
+// recordLeaders counts a slot with leaders
+func (r *LeaderReport) recordLeaders(leaders []*Validator) {
+	honest := 0
+	for _, leader := range leaders {
+		if !leader.IsMalicious {
+			honest++
+		}
+	}
+	switch {
+	case len(leaders) == 0:
+		r.EmptySlots++
+		r.CharacteristicString += "_"
+	case honest < len(leaders):
+		r.AdversarialSlots++
+		r.CharacteristicString += "A"
+	case honest == 1:
+		r.UniqueHonestSlots++
+		r.CharacteristicString += "h"
+	default:
+		r.MultiHonestSlots++
+		r.CharacteristicString += "H"
+	}
+}
+
+// recordElection counts the seats malicious validators won among delegates
+// and their share of votes, the tokens behind every validator or its backing
+// under npos, by address
+func (sim *Simulation) recordElection(delegates []*Validator, votes map[string]float64) {
+	if len(delegates) == 0 {
+		return
+	}
+	r := sim.security.Elections
+	r.DelegateElections++
+	seats := 0
+	for _, delegate := range delegates {
+		if delegate.IsMalicious {
+			seats++
+		}
+	}
+	r.CartelSeats += seats
+	if seats > len(delegates)/2 {
+		r.CartelMajorityElections++
+	}
+	total, cartel := 0.0, 0.0
+	for _, validator := range sim.validators {
+		total += votes[validator.Address]
+		if validator.IsMalicious {
+			cartel += votes[validator.Address]
+		}
+	}
+	r.CartelVoteShare = 0
+	if total > 0 {
+		r.CartelVoteShare = cartel / total
+	}
+	r.MinBacking = votes[delegates[0].Address]
+	for _, delegate := range delegates {
+		r.MinBacking = math.Min(r.MinBacking, votes[delegate.Address])
+	}
+}
+
+// recordTimeout counts a round or view that timed out, the last of streak in
+// a row
+func (r *LivenessReport) recordTimeout(streak int) {
+	r.TimedOutViews++
+	if streak > r.LongestTimeoutStreak {
+		r.LongestTimeoutStreak = streak
+	}
+}
+
+// recordConsensus counts a longest chain consensus round
+func (sim *Simulation) recordConsensus(delayed bool) {
+	sim.security.ConsensusRounds++
+	if delayed {
+		sim.security.DelayedConsensusRounds++
+		sim.delayStreak++
+		if sim.delayStreak > sim.security.LongestConsensusDelay {
+			sim.security.LongestConsensusDelay = sim.delayStreak
+		}
+	} else {
+		sim.delayStreak = 0
+	}
+}
+
+// recordReorg counts a consensus round that dropped depth blocks from the
+// chain of at least one validator
+func (sim *Simulation) recordReorg(depth int) {
+	if depth == 0 {
+		return
+	}
+	sim.security.Reorgs++
+	sim.security.TotalReorgDepth += depth
+	if depth > sim.security.MaxReorgDepth {
+		sim.security.MaxReorgDepth = depth
+	}
+}
+
+// reorgDepth counts the blocks at the tip of chain that are not on canonical
+func reorgDepth(chain []Block, canonical []Block) int {
+	common := 0
+	for common < len(chain) && common < len(canonical) && chain[common].Hash == canonical[common].Hash {
+		common++
+	}
+	return len(chain) - common
+}
+
+// securityReport completes the outcomes gathered so far with the state of the
+// network
+func (sim *Simulation) securityReport() SecurityReport {
+	report := sim.security
+	report.Attack = sim.scenario.Attack
+	report.BlockchainType = sim.blockchainType
+	report.Finality = sim.scenario.Finality
+	report.ForkChoice = sim.scenario.ForkChoice
+	report.LongestConsensusDelaySlots = report.LongestConsensusDelay * sim.scenario.ConsensusInterval
+	report.ConsensusDelayedAtEndOfRun = sim.delayStreak > 0
+	//the sub-reports keep counting after this one, so complete copies of them
+	if sim.security.Casper != nil {
+		casper := *sim.security.Casper
+		casper.FinalizedBlocks = len(sim.FinalizedBlockchain())
+		report.Casper = &casper
+	}
+	if sim.security.Censorship != nil {
+		censorship := *sim.security.Censorship
+		//only the transactions still settled at the end count as included
+		for id, applied := range sim.applied {
+			if _, ok := sim.sentAt[id]; ok {
+				censorship.recordInclusion(applied.wait, applied.transaction.Sender.targeted, sim.censored[id])
+			}
+		}
+		if censorship.CensoredIncluded > 0 {
+			censorship.MeanCensoredWaitSlots = float64(censorship.TotalCensoredWaitSlots) / float64(censorship.CensoredIncluded)
+		}
+		if censorship.TargetedIncluded > 0 {
+			censorship.MeanTargetedInclusionSlots = float64(censorship.TotalTargetedInclusionSlots) / float64(censorship.TargetedIncluded)
+		}
+		if censorship.OtherIncluded > 0 {
+			censorship.MeanOtherInclusionSlots = float64(censorship.TotalOtherInclusionSlots) / float64(censorship.OtherIncluded)
+		}
+		for _, user := range sim.users {
+			if user.targeted {
+				censorship.PendingTargeted += user.sent - user.included
+			}
+		}
+		report.Censorship = &censorship
+	}
+	if report.ResolvedForks > 0 {
+		report.MeanForkResolutionSlots = float64(report.TotalForkResolutionSlots) / float64(report.ResolvedForks)
+	}
+
+	report.TotalBlocks = len(sim.CertifiedBlockchain)
+	for _, block := range sim.CertifiedBlockchain {
+		if block.IsMalicious {
+			report.MaliciousBlocks++
+		}
+		for _, transaction := range block.Transactions {
+			report.TotalRewards += transaction.Reward
+			if block.IsMalicious {
+				report.MaliciousRewards += transaction.Reward
+			}
+		}
+	}
+	if report.TotalBlocks > 0 {
+		report.MaliciousBlockShare = float64(report.MaliciousBlocks) / float64(report.TotalBlocks)
+	}
+	if report.TotalRewards > 0 {
+		report.MaliciousRewardShare = report.MaliciousRewards / report.TotalRewards
+	}
+
+	initialStake := 0.0
+	finalStake := 0.0
+	for _, validator := range sim.validators {
+		initialStake += validator.initialStake
+		finalStake += validator.Stake
+		if validator.IsMalicious {
+			report.MaliciousValidators++
+			report.MaliciousInitialStake += validator.initialStake
+			report.MaliciousFinalStake += validator.Stake
+		}
+	}
+	report.MaliciousStakeChange = report.MaliciousFinalStake - report.MaliciousInitialStake
+	if initialStake > 0 {
+		report.MaliciousInitialStakeShare = report.MaliciousInitialStake / initialStake
+	}
+	if finalStake > 0 {
+		report.MaliciousFinalStakeShare = report.MaliciousFinalStake / finalStake
+	}
+	return report
+}
+
+// Markdown renders the report for people to read
+func (r SecurityReport) Markdown() string {
+	var b strings.Builder
+	if r.ForkChoice == "lmd_ghost" {
+		fmt.Fprintf(&b, "# Security report: %s on %s with LMD-GHOST\n\n", r.Attack, r.BlockchainType)
+	} else {
+		fmt.Fprintf(&b, "# Security report: %s on %s\n\n", r.Attack, r.BlockchainType)
+	}
+	fmt.Fprintf(&b, "%d time slots, %d longest chain consensus rounds.\n\n", r.Slots, r.ConsensusRounds)
+
+	if p := r.Partition; p != nil {
+		b.WriteString("## Network partition\n\n")
+		b.WriteString("| Metric | Value |\n|---|---|\n")
+		fmt.Fprintf(&b, "| Slots forked | %d |\n", p.ForkedSlots)
+		fmt.Fprintf(&b, "| Longest fork (slots) | %d |\n", p.LongestForkedSlots)
+		fmt.Fprintf(&b, "| Reorgs | %d |\n", r.Reorgs)
+		fmt.Fprintf(&b, "| Deepest reorg (blocks) | %d |\n", r.MaxReorgDepth)
+		fmt.Fprintf(&b, "| Blocks reorganised | %d |\n\n", r.TotalReorgDepth)
+	}
+	if hasAttack(r.Attack, "balance") {
+		b.WriteString("## Balance\n\n")
+		b.WriteString("| Metric | Value |\n|---|---|\n")
+		fmt.Fprintf(&b, "| Consensus rounds delayed | %d of %d |\n", r.DelayedConsensusRounds, r.ConsensusRounds)
+		fmt.Fprintf(&b, "| Longest delay | %d rounds (%d slots) |\n", r.LongestConsensusDelay, r.LongestConsensusDelaySlots)
+		fmt.Fprintf(&b, "| Still delayed at end of run | %t |\n", r.ConsensusDelayedAtEndOfRun)
+		fmt.Fprintf(&b, "| Reorgs | %d |\n", r.Reorgs)
+		fmt.Fprintf(&b, "| Deepest reorg (blocks) | %d |\n\n", r.MaxReorgDepth)
+	}
+
+	if l := r.Leaders; l != nil {
+		b.WriteString("## Slot leaders\n\n")
+		b.WriteString("| Metric | Value |\n|---|---|\n")
+		fmt.Fprintf(&b, "| Empty slots | %d |\n", l.EmptySlots)
+		fmt.Fprintf(&b, "| Slots with a single honest leader | %d |\n", l.UniqueHonestSlots)
+		fmt.Fprintf(&b, "| Slots with several honest leaders | %d |\n", l.MultiHonestSlots)
+		fmt.Fprintf(&b, "| Slots with a malicious leader | %d |\n", l.AdversarialSlots)
+		fmt.Fprintf(&b, "| Characteristic string | `%s` |\n\n", l.CharacteristicString)
+	}
+	if d := r.Dos; d != nil {
+		b.WriteString("## Targeted DoS\n\n")
+		b.WriteString("| Metric | Value |\n|---|---|\n")
+		fmt.Fprintf(&b, "| Validators knocked offline | %d |\n", d.DosTargets)
+		fmt.Fprintf(&b, "| Of them proposing or on the committee | %d |\n", d.DosHits)
+		fmt.Fprintf(&b, "| Slots skipped | %d of %d |\n", d.DosSkippedSlots, r.Slots)
+		fmt.Fprintf(&b, "| Committees handed a malicious majority | %d |\n\n", d.CapturedCommittees)
+	}
+	if l := r.Liveness; l != nil {
+		b.WriteString("## BFT liveness\n\n")
+		b.WriteString("| Metric | Value |\n|---|---|\n")
+		fmt.Fprintf(&b, "| Views timed out | %d of %d |\n", l.TimedOutViews, r.Slots)
+		fmt.Fprintf(&b, "| Longest run of timeouts | %d |\n", l.LongestTimeoutStreak)
+		if r.BlockchainType == "hotstuff" {
+			fmt.Fprintf(&b, "| Blocks certified | %d |\n", l.CertifiedBlocks)
+		}
+		fmt.Fprintf(&b, "| Blocks committed | %d |\n\n", r.TotalBlocks)
+	}
+	if e := r.Elections; e != nil {
+		b.WriteString("## Delegate elections\n\n")
+		b.WriteString("| Metric | Value |\n|---|---|\n")
+		fmt.Fprintf(&b, "| Vote transactions included | %d |\n", e.VoteTransactions)
+		fmt.Fprintf(&b, "| Elections | %d |\n", e.DelegateElections)
+		fmt.Fprintf(&b, "| Seats won by malicious validators | %d |\n", e.CartelSeats)
+		fmt.Fprintf(&b, "| Elections with a malicious majority | %d |\n", e.CartelMajorityElections)
+		fmt.Fprintf(&b, "| Malicious share of votes at the last election | %.1f%% |\n", 100*e.CartelVoteShare)
+		fmt.Fprintf(&b, "| Lowest backing of a delegate at the last election | %.2f |\n", e.MinBacking)
+		if v := r.VoteBuying; v != nil {
+			fmt.Fprintf(&b, "| Votes bought | %d |\n", v.VotesBought)
+			fmt.Fprintf(&b, "| Bribes paid | %.2f |\n", v.BribesPaid)
+		}
+		b.WriteString("\n")
+	}
+	if l := r.LongRange; l != nil {
+		b.WriteString("## Long range\n\n")
+		b.WriteString("| Metric | Value |\n|---|---|\n")
+		fmt.Fprintf(&b, "| Joining validators offered a forged chain | %d |\n", l.LongRangeJoiners)
+		fmt.Fprintf(&b, "| Of them bootstrapped on it | %d |\n", l.LongRangeCaptured)
+		fmt.Fprintf(&b, "| Keys held at the last attempt | %d, %d of them retired |\n", l.LongRangeKeys, l.RetiredKeys)
+		fmt.Fprintf(&b, "| Reorgs | %d |\n", r.Reorgs)
+		fmt.Fprintf(&b, "| Deepest reorg (blocks) | %d |\n\n", r.MaxReorgDepth)
+	}
+	if n := r.NothingAtStake; n != nil {
+		b.WriteString("## Nothing at stake\n\n")
+		b.WriteString("| Metric | Value |\n|---|---|\n")
+		fmt.Fprintf(&b, "| Blocks signed on a second branch | %d |\n", n.NothingAtStakeBlocks)
+		fmt.Fprintf(&b, "| Of them accepted | %d |\n", n.NothingAtStakeAccepted)
+		fmt.Fprintf(&b, "| Equivocators slashed | %d |\n", r.SlashedEquivocators)
+		fmt.Fprintf(&b, "| Forks | %d, %d of them resolved |\n", r.Forks, r.ResolvedForks)
+		fmt.Fprintf(&b, "| Mean fork resolution (slots) | %.1f |\n", r.MeanForkResolutionSlots)
+		fmt.Fprintf(&b, "| Longest fork resolution (slots) | %d |\n", r.LongestForkResolutionSlots)
+		fmt.Fprintf(&b, "| Consensus rounds delayed | %d of %d |\n\n", r.DelayedConsensusRounds, r.ConsensusRounds)
+	}
+	if s := r.Selfish; s != nil {
+		b.WriteString("## Selfish proposing\n\n")
+		b.WriteString("| Metric | Value |\n|---|---|\n")
+		fmt.Fprintf(&b, "| Blocks withheld | %d |\n", s.WithheldBlocks)
+		fmt.Fprintf(&b, "| Private chains released | %d |\n", s.PrivateChainsReleased)
+		fmt.Fprintf(&b, "| Private chains abandoned | %d |\n", s.PrivateChainsAbandoned)
+		fmt.Fprintf(&b, "| Honest blocks orphaned | %d |\n", s.OrphanedBlocks)
+		fmt.Fprintf(&b, "| Malicious share of rewards | %.1f%% |\n", 100*r.MaliciousRewardShare)
+		fmt.Fprintf(&b, "| Malicious share of stake at the start | %.1f%% |\n\n", 100*r.MaliciousInitialStakeShare)
+	}
+	if c := r.Censorship; c != nil {
+		b.WriteString("## Censorship\n\n")
+		b.WriteString("| Metric | Value |\n|---|---|\n")
+		fmt.Fprintf(&b, "| Transactions left out | %d, %d times |\n", c.CensoredTransactions, c.CensorshipExclusions)
+		fmt.Fprintf(&b, "| Of them included later | %d |\n", c.CensoredIncluded)
+		fmt.Fprintf(&b, "| Slots a censored transaction waited | %.1f on average, %d at most |\n", c.MeanCensoredWaitSlots, c.LongestCensoredWaitSlots)
+		fmt.Fprintf(&b, "| Inclusion latency of targeted users (slots) | %.1f on average, %d at most, over %d transactions |\n", c.MeanTargetedInclusionSlots, c.MaxTargetedInclusionSlots, c.TargetedIncluded)
+		fmt.Fprintf(&b, "| Inclusion latency of other users (slots) | %.1f on average, %d at most, over %d transactions |\n", c.MeanOtherInclusionSlots, c.MaxOtherInclusionSlots, c.OtherIncluded)
+		fmt.Fprintf(&b, "| Transactions of targeted users still waiting | %d |\n\n", c.PendingTargeted)
+	}
+	if d := r.DoubleSpend; d != nil {
+		b.WriteString("## Double spend\n\n")
+		b.WriteString("| Metric | Value |\n|---|---|\n")
+		fmt.Fprintf(&b, "| Forks spent on both branches | %d |\n", d.DoubleSpends)
+		fmt.Fprintf(&b, "| Both transactions confirmed before the reorg | %d |\n", d.DoubleSpendsBothConfirmed)
+		fmt.Fprintf(&b, "| Amount spent twice | %.2f |\n", d.DoubleSpentAmount)
+		fmt.Fprintf(&b, "| First branch's transaction kept | %d |\n", d.DoubleSpendsFirstSurvived)
+		fmt.Fprintf(&b, "| Second branch's transaction kept | %d |\n", d.DoubleSpendsSecondSurvived)
+		fmt.Fprintf(&b, "| Neither kept | %d |\n\n", d.DoubleSpendsLost)
+	}
+	if c := r.Casper; c != nil {
+		b.WriteString("## Casper FFG\n\n")
+		b.WriteString("| Metric | Value |\n|---|---|\n")
+		fmt.Fprintf(&b, "| Checkpoints justified | %d |\n", c.JustifiedCheckpoints)
+		fmt.Fprintf(&b, "| Checkpoints finalized | %d |\n", c.FinalizedCheckpoints)
+		fmt.Fprintf(&b, "| Finalized blocks | %d of %d |\n", c.FinalizedBlocks, r.TotalBlocks)
+		fmt.Fprintf(&b, "| Double votes | %d |\n", c.DoubleVotes)
+		fmt.Fprintf(&b, "| Surround votes | %d |\n", c.SurroundVotes)
+		fmt.Fprintf(&b, "| Attesters slashed | %d |\n\n", c.SlashedAttesters)
+	}
+
+	b.WriteString("## Malicious validators\n\n")
+	b.WriteString("| Metric | Value |\n|---|---|\n")
+	fmt.Fprintf(&b, "| Malicious blocks | %d of %d (%.1f%%) |\n", r.MaliciousBlocks, r.TotalBlocks, 100*r.MaliciousBlockShare)
+	fmt.Fprintf(&b, "| Malicious validators | %d |\n", r.MaliciousValidators)
+	fmt.Fprintf(&b, "| Stake | %.2f -> %.2f (%+.2f) |\n", r.MaliciousInitialStake, r.MaliciousFinalStake, r.MaliciousStakeChange)
+	fmt.Fprintf(&b, "| Share of all stake | %.1f%% -> %.1f%% |\n", 100*r.MaliciousInitialStakeShare, 100*r.MaliciousFinalStakeShare)
+	return b.String()
+}
+
+// hasAttack reports whether name is one of the attacks joined in attack
+func hasAttack(attack string, name string) bool {
+	return slices.Contains(strings.Split(attack, "+"), name)
+}
+
+// WriteSecurityReport saves report to path, as JSON if path ends in .json and
+// as Markdown otherwise
+func WriteSecurityReport(path string, report SecurityReport) error {
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, append(data, '\n'), 0644)
+	}
+	return os.WriteFile(path, []byte(report.Markdown()), 0644)
+}
@@ -0,0 +1,80 @@
+package pos
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecurityReportOnlyShowsTheActiveAttack(t *testing.T) {
+	for _, test := range []struct {
+		attack    string
+		partition bool
+	}{
+		{"network_partition", true},
+		{"balance", false},
+		{"none", false},
+	} {
+		t.Run(test.attack, func(t *testing.T) {
+			s := DefaultScenario()
+			s.Attack = test.attack
+			report := newSecurityReport(s)
+			report.Attack = test.attack
+			if got := report.Partition != nil; got != test.partition {
+				t.Fatalf("partition metrics reported: %t, want %t", got, test.partition)
+			}
+			if got := strings.Contains(report.Markdown(), "## Network partition"); got != test.partition {
+				t.Errorf("Markdown has a network partition section: %t, want %t", got, test.partition)
+			}
+			if got := strings.Contains(report.Markdown(), "## Balance"); got != (test.attack == "balance") {
+				t.Errorf("Markdown has a balance section: %t", got)
+			}
+			data, err := json.Marshal(report)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			var keys map[string]json.RawMessage
+			if err := json.Unmarshal(data, &keys); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if _, got := keys["partition"]; got != test.partition {
+				t.Errorf("JSON has a partition key: %t, want %t", got, test.partition)
+			}
+		})
+	}
+}
+
+func TestWriteSecurityReport(t *testing.T) {
+	report := SecurityReport{Attack: "network_partition", BlockchainType: "pos", Partition: &PartitionReport{ForkedSlots: 4, LongestForkedSlots: 3}}
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "report.json")
+	if err := WriteSecurityReport(path, report); err != nil {
+		t.Fatalf("WriteSecurityReport: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	var read SecurityReport
+	if err := json.Unmarshal(data, &read); err != nil {
+		t.Fatalf("the report is not JSON: %v", err)
+	}
+	if read.Partition == nil || *read.Partition != *report.Partition {
+		t.Errorf("partition = %+v, want %+v", read.Partition, report.Partition)
+	}
+
+	path = filepath.Join(dir, "report.md")
+	if err := WriteSecurityReport(path, report); err != nil {
+		t.Fatalf("WriteSecurityReport: %v", err)
+	}
+	data, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "# Security report: network_partition on pos") {
+		t.Errorf("the report is not Markdown:\n%s", data)
+	}
+}
@@ -0,0 +1,293 @@
+package pos
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario describes one experiment: the network, the protocol under test, the
+// attack and how long to run it
+type Scenario struct {
+	// headless, manual or auto
+	RunType       string `yaml:"runType" json:"runType"`
+	NumValidators int    `yaml:"numValidators" json:"numValidators"`
+	NumUsers      int    `yaml:"numUsers" json:"numUsers"`
+	NumMal        int    `yaml:"numMal" json:"numMal"`
+	CommitteeSize int    `yaml:"committeeSize" json:"committeeSize"`
+	DelegateSize  int    `yaml:"delegateSize" json:"delegateSize"`
+	// pos, slashing, reputation, tendermint, ouroboros, hotstuff, dpos or npos
+	BlockchainType string `yaml:"blockchainType" json:"blockchainType"`
+	// network_partition, balance, targeted_dos, vote_buying, long_range,
+	// nothing_at_stake, selfish_proposing, censorship, double_spend or none,
+	// or several attacks joined by "+" such as network_partition+balance
+	Attack string `yaml:"attack" json:"attack"`
+	// none, or casper_ffg to finalize checkpoints on top of longest chain
+	// consensus
+	Finality string `yaml:"finality" json:"finality"`
+	// central has the server draw the committee and proposer of pos and
+	// slashing, vrf has validators select themselves in secret
+	Sortition string `yaml:"sortition" json:"sortition"`
+	// Validators a targeted_dos attacker can knock offline every slot
+	DosBudget int `yaml:"dosBudget" json:"dosBudget"`
+	// longest_chain, or lmd_ghost to follow the subtree with the most stake
+	// attesting to it
+	ForkChoice string `yaml:"forkChoice" json:"forkChoice"`
+	// Blocks between two casper_ffg checkpoints, time slots between two rounds
+	// of attestations and time slots of an ouroboros epoch
+	EpochLength int `yaml:"epochLength" json:"epochLength"`
+	// Chance that an ouroboros slot has a leader, were one validator to hold
+	// all the stake
+	ActiveSlotCoefficient float64 `yaml:"activeSlotCoefficient" json:"activeSlotCoefficient"`
+	// Chance that a transaction of a user is a vote under dpos or npos
+	VoteRate float64 `yaml:"voteRate" json:"voteRate"`
+	// Validators a user nominates at most under npos
+	MaxNominations int `yaml:"maxNominations" json:"maxNominations"`
+	// Share of the tokens a user locks that a vote_buying cartel pays it to
+	// vote for one of its members
+	Bribe float64 `yaml:"bribe" json:"bribe"`
+	// Height of the certified chain a long_range attacker rewrites history
+	// from
+	LongRangeForkHeight int `yaml:"longRangeForkHeight" json:"longRangeForkHeight"`
+	// Time slots between two honest validators joining after the start, 0
+	// for none
+	JoinInterval int `yaml:"joinInterval" json:"joinInterval"`
+	// Whether validators signing two blocks or voting for both blocks of a
+	// time slot are slashed by SlashRatio
+	SlashEquivocation bool `yaml:"slashEquivocation" json:"slashEquivocation"`
+	// Time slots a selfish_proposing attacker withholds its private chain at
+	// least before releasing it
+	WithholdSlots int `yaml:"withholdSlots" json:"withholdSlots"`
+	// Users a censorship attacker targets, the first ones to join
+	CensoredUsers int `yaml:"censoredUsers" json:"censoredUsers"`
+
+	// Seed for all randomness in the run, 0 picks one from the current time
+	Seed int64 `yaml:"seed" json:"seed"`
+	// The run stops after NumSlots time slots, once the certified chain holds
+	// MaxBlocks blocks or after Duration on its clock, whichever comes first.
+	// 0 leaves a bound out.
+	NumSlots  int      `yaml:"numSlots" json:"numSlots"`
+	MaxBlocks int      `yaml:"maxBlocks" json:"maxBlocks"`
+	Duration  Duration `yaml:"duration" json:"duration"`
+	// virtual fast-forwards through slots, real waits for them
+	Clock               string   `yaml:"clock" json:"clock"`
+	SlotDuration        Duration `yaml:"slotDuration" json:"slotDuration"`
+	TransactionInterval Duration `yaml:"transactionInterval" json:"transactionInterval"`
+	// Time slots between two longest chain consensus rounds
+	ConsensusInterval int `yaml:"consensusInterval" json:"consensusInterval"`
+
+	// Fraction of stake a slashed validator keeps
+	SlashRatio float64 `yaml:"slashRatio" json:"slashRatio"`
+	// Fraction of reputation kept by a proposer whose block is rejected or who forked the chain
+	ReputationSlashRatio float64 `yaml:"reputationSlashRatio" json:"reputationSlashRatio"`
+	// Fraction of reputation kept by a delegate who voted against the majority
+	VoteReputationSlashRatio float64 `yaml:"voteReputationSlashRatio" json:"voteReputationSlashRatio"`
+
+	StakeDistribution   Distribution `yaml:"stakeDistribution" json:"stakeDistribution"`
+	BalanceDistribution Distribution `yaml:"balanceDistribution" json:"balanceDistribution"`
+}
+
+// Distribution draws initial stakes and balances
+type Distribution struct {
+	// uniform, fixed or normal
+	Kind string  `yaml:"kind" json:"kind"`
+	Min  float64 `yaml:"min" json:"min"`
+	Max  float64 `yaml:"max" json:"max"`
+	// Mean and StdDev are only used by normal, which is clamped to [Min, Max]
+	Mean   float64 `yaml:"mean,omitempty" json:"mean,omitempty"`
+	StdDev float64 `yaml:"stdDev,omitempty" json:"stdDev,omitempty"`
+}
+
+// Duration is a time.Duration written as a string such as "5s"
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// blockchainTypes lists the protocols newConsensusProtocol knows
+var blockchainTypes = []string{"pos", "slashing", "reputation", "tendermint", "ouroboros", "hotstuff", "dpos", "npos"}
+
+// attacks lists the attacks newAttack knows
+var attacks = []string{"network_partition", "balance", "targeted_dos", "vote_buying", "long_range", "nothing_at_stake", "selfish_proposing", "censorship", "double_spend", "none"}
+
+// DefaultScenario returns the scenario the simulator has always run
+func DefaultScenario() Scenario {
+	return Scenario{
+		RunType:                  "auto",
+		NumValidators:            10,
+		NumUsers:                 3,
+		NumMal:                   7,
+		CommitteeSize:            4,
+		DelegateSize:             3,
+		BlockchainType:           "slashing",
+		Attack:                   "network_partition",
+		Finality:                 "none",
+		ForkChoice:               "longest_chain",
+		Sortition:                "central",
+		DosBudget:                2,
+		EpochLength:              5,
+		ActiveSlotCoefficient:    0.5,
+		VoteRate:                 0.2,
+		MaxNominations:           3,
+		Bribe:                    0.05,
+		LongRangeForkHeight:      1,
+		WithholdSlots:            3,
+		CensoredUsers:            1,
+		Seed:                     0,
+		NumSlots:                 100,
+		Clock:                    "virtual",
+		SlotDuration:             Duration(5 * time.Second),
+		TransactionInterval:      Duration(4 * time.Second),
+		ConsensusInterval:        5,
+		SlashRatio:               0.2,
+		ReputationSlashRatio:     0.2,
+		VoteReputationSlashRatio: 0.5,
+		StakeDistribution:        Distribution{Kind: "uniform", Min: 300, Max: 1000},
+		BalanceDistribution:      Distribution{Kind: "uniform", Min: 10, Max: 1010},
+	}
+}
+
+// LoadScenario reads a YAML or JSON scenario file, depending on its extension.
+// Fields missing from the file keep their DefaultScenario values.
+func LoadScenario(path string) (Scenario, error) {
+	scenario := DefaultScenario()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return scenario, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&scenario)
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&scenario)
+	default:
+		err = fmt.Errorf("unknown scenario format %q, expected .yaml, .yml or .json", filepath.Ext(path))
+	}
+	if err != nil {
+		return scenario, fmt.Errorf("%s: %w", path, err)
+	}
+
+	err = scenario.Validate()
+	if err != nil {
+		return scenario, fmt.Errorf("%s: %w", path, err)
+	}
+	return scenario, nil
+}
+
+// Validate reports every invalid value in the scenario
+func (s Scenario) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(s.RunType == "headless" || s.RunType == "manual" || s.RunType == "auto", "unknown runType %q, expected headless, manual or auto", s.RunType)
+	check(slices.Contains(blockchainTypes, s.BlockchainType), "unknown blockchainType %q, expected one of %s", s.BlockchainType, strings.Join(blockchainTypes, ", "))
+	attackNames := strings.Split(s.Attack, "+")
+	for i, name := range attackNames {
+		check(slices.Contains(attacks, name), "unknown attack %q, expected one of %s joined by +", name, strings.Join(attacks, ", "))
+		check(!slices.Contains(attackNames[:i], name), "attack %q is listed twice", name)
+		check(name != "none" || len(attackNames) == 1, "attack none cannot be combined with other attacks")
+	}
+	check(s.Finality == "none" || s.Finality == "casper_ffg", "unknown finality %q, expected none or casper_ffg", s.Finality)
+	check(s.ForkChoice == "longest_chain" || s.ForkChoice == "lmd_ghost", "unknown forkChoice %q, expected longest_chain or lmd_ghost", s.ForkChoice)
+	check(s.Sortition == "central" || s.Sortition == "vrf", "unknown sortition %q, expected central or vrf", s.Sortition)
+	check(s.DosBudget >= 0, "dosBudget must not be negative")
+	check(s.EpochLength > 0, "epochLength must be positive")
+	check(s.ActiveSlotCoefficient > 0 && s.ActiveSlotCoefficient <= 1, "activeSlotCoefficient must be above 0 and at most 1")
+	check(s.VoteRate >= 0 && s.VoteRate <= 1, "voteRate must be between 0 and 1")
+	check(s.MaxNominations > 0, "maxNominations must be positive")
+	check(s.Bribe >= 0, "bribe must not be negative")
+	check(s.LongRangeForkHeight > 0, "longRangeForkHeight must be positive")
+	check(s.JoinInterval >= 0, "joinInterval must not be negative")
+	check(s.WithholdSlots >= 0, "withholdSlots must not be negative")
+	check(s.CensoredUsers >= 0, "censoredUsers must not be negative")
+	check(s.NumValidators >= 0, "numValidators must not be negative")
+	check(s.NumUsers >= 0, "numUsers must not be negative")
+	check(s.NumMal >= 0 && s.NumMal <= s.NumValidators, "numMal must be between 0 and numValidators (%d)", s.NumValidators)
+	check(s.CommitteeSize > 0, "committeeSize must be positive")
+	check(s.DelegateSize > 0, "delegateSize must be positive")
+	if slices.Contains([]string{"reputation", "dpos", "npos"}, s.BlockchainType) && s.RunType != "manual" {
+		check(s.DelegateSize <= s.NumValidators, "delegateSize must not exceed numValidators (%d)", s.NumValidators)
+	}
+	check(s.NumSlots >= 0, "numSlots must not be negative")
+	check(s.MaxBlocks >= 0, "maxBlocks must not be negative")
+	check(s.Duration >= 0, "duration must not be negative")
+	if s.RunType == "headless" {
+		check(s.NumSlots > 0 || s.MaxBlocks > 0 || s.Duration > 0, "headless runs need numSlots, maxBlocks or duration to stop")
+	}
+	check(s.Clock == "virtual" || s.Clock == "real", "unknown clock %q, expected virtual or real", s.Clock)
+	check(s.SlotDuration > 0, "slotDuration must be positive")
+	check(s.TransactionInterval > 0, "transactionInterval must be positive")
+	check(s.ConsensusInterval > 0, "consensusInterval must be positive")
+	check(s.SlashRatio >= 0 && s.SlashRatio <= 1, "slashRatio must be between 0 and 1")
+	check(s.ReputationSlashRatio >= 0 && s.ReputationSlashRatio <= 1, "reputationSlashRatio must be between 0 and 1")
+	check(s.VoteReputationSlashRatio >= 0 && s.VoteReputationSlashRatio <= 1, "voteReputationSlashRatio must be between 0 and 1")
+	if err := s.StakeDistribution.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("stakeDistribution: %w", err))
+	}
+	if err := s.BalanceDistribution.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("balanceDistribution: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+func (d Distribution) validate() error {
+	if d.Kind != "uniform" && d.Kind != "fixed" && d.Kind != "normal" {
+		return fmt.Errorf("unknown kind %q, expected uniform, fixed or normal", d.Kind)
+	}
+	if d.Min < 0 || d.Max < d.Min {
+		return errors.New("min must not be negative or greater than max")
+	}
+	if d.Kind == "normal" && d.StdDev < 0 {
+		return errors.New("stdDev must not be negative")
+	}
+	return nil
+}
+
+// sample draws a value from the distribution using the run's randomness
+func (d Distribution) sample(rng *rand.Rand) float64 {
+	switch d.Kind {
+	case "fixed":
+		return d.Min
+	case "normal":
+		return math.Min(d.Max, math.Max(d.Min, rng.NormFloat64()*d.StdDev+d.Mean))
+	default:
+		return rng.Float64()*(d.Max-d.Min) + d.Min
+	}
+}
+
+// newClock returns the clock the scenario asks for
+func (s Scenario) newClock() Clock {
+	if s.Clock == "real" {
+		return NewRealClock()
+	}
+	return NewVirtualClock(time.Unix(0, 0).UTC())
+}
@@ -0,0 +1,63 @@
+package pos
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScenarioValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(s *Scenario)
+		// Substring of the error, empty if the scenario is valid
+		err string
+	}{
+		{"default", func(s *Scenario) {}, ""},
+		{"joined attacks", func(s *Scenario) { s.Attack = "network_partition+balance" }, ""},
+		{"unknown run type", func(s *Scenario) { s.RunType = "batch" }, `unknown runType "batch"`},
+		{"unknown blockchain type", func(s *Scenario) { s.BlockchainType = "pow" }, `unknown blockchainType "pow"`},
+		{"unknown attack", func(s *Scenario) { s.Attack = "sybil" }, `unknown attack "sybil"`},
+		{"attack listed twice", func(s *Scenario) { s.Attack = "balance+balance" }, `attack "balance" is listed twice`},
+		{"none joined", func(s *Scenario) { s.Attack = "none+balance" }, "attack none cannot be combined"},
+		{"too many malicious", func(s *Scenario) { s.NumMal = s.NumValidators + 1 }, "numMal must be between 0 and numValidators"},
+		{"too many delegates", func(s *Scenario) { s.BlockchainType = "reputation"; s.DelegateSize = s.NumValidators + 1 }, "delegateSize must not exceed numValidators"},
+		{"too many dpos delegates", func(s *Scenario) { s.BlockchainType = "dpos"; s.DelegateSize = s.NumValidators + 1 }, "delegateSize must not exceed numValidators"},
+		{"vote rate above 1", func(s *Scenario) { s.VoteRate = 1.5 }, "voteRate must be between 0 and 1"},
+		{"delegates join later in manual runs", func(s *Scenario) {
+			s.RunType = "manual"
+			s.BlockchainType = "reputation"
+			s.DelegateSize = s.NumValidators + 1
+		}, ""},
+		{"unknown clock", func(s *Scenario) { s.Clock = "wall" }, `unknown clock "wall"`},
+		{"bad distribution", func(s *Scenario) { s.StakeDistribution.Max = s.StakeDistribution.Min - 1 }, "stakeDistribution"},
+		{"slash ratio above 1", func(s *Scenario) { s.SlashRatio = 1.5 }, "slashRatio must be between 0 and 1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := DefaultScenario()
+			test.modify(&s)
+			err := s.Validate()
+			switch {
+			case test.err == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case test.err != "" && err == nil:
+				t.Errorf("expected an error containing %q", test.err)
+			case test.err != "" && !strings.Contains(err.Error(), test.err):
+				t.Errorf("error %q does not contain %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestShippedScenariosAreValid(t *testing.T) {
+	paths, _ := filepath.Glob("../scenarios/*")
+	if len(paths) == 0 {
+		t.Fatal("no scenarios found")
+	}
+	for _, path := range paths {
+		if _, err := LoadScenario(path); err != nil {
+			t.Errorf("%v", err)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package pos
+
+import "fmt"
+
+// selfishProposing has malicious proposers withhold their blocks. A
+// malicious proposer signs its block on the private chain of the attacker
+// instead of putting it to the committee, leaving the slot empty for the
+// honest chain. At a longest chain consensus round the attacker releases
+// its private chain once it has withheld blocks for withholdSlots slots and
+// the chain is longer than the honest one, orphaning the honest blocks since
+// the fork, keeps withholding while the honest chain is not longer and
+// abandons the private chain otherwise.
+type selfishProposing struct {
+	baseAttack
+	private []Block
+	// Blocks the private chain shares with the honest one, and the slot the
+	// attacker withheld its first private block in
+	fork, since int
+}
+
+func (s *selfishProposing) OnBlockGenerated(sim *Simulation, slot *Slot) error {
+	if !slot.Proposer.IsMalicious {
+		return nil
+	}
+	if s.private == nil {
+		s.private = make([]Block, len(slot.Proposer.Blockchain))
+		copy(s.private, slot.Proposer.Blockchain)
+		s.fork = len(s.private)
+		s.since = sim.roundCount
+	}
+	block, err := sim.generateBlockOn(slot.Proposer, s.private[len(s.private)-1], nil)
+	if err != nil {
+		return err
+	}
+	s.private = append(s.private, block)
+	sim.security.Selfish.WithheldBlocks++
+	return fmt.Errorf("Proposer %s withholds block %d", slot.Proposer.Address[:3], block.Index)
+}
+
+func (s *selfishProposing) OnConsensus(sim *Simulation, chosen *Validator) *Validator {
+	if s.private == nil || chosen == nil {
+		return chosen
+	}
+	honest := chosen.Blockchain
+	switch {
+	case len(honest) > len(s.private):
+		fmt.Fprintf(sim.out, "Attacker abandons its private chain of %d blocks\n", len(s.private))
+		sim.security.Selfish.PrivateChainsAbandoned++
+		s.private = nil
+		return chosen
+	case len(honest) == len(s.private) || sim.roundCount-s.since < sim.scenario.WithholdSlots:
+		return chosen
+	}
+
+	fmt.Fprintf(sim.out, "Attacker releases its private chain of %d blocks\n", len(s.private))
+	sim.security.Selfish.PrivateChainsReleased++
+	sim.security.Selfish.OrphanedBlocks += reorgDepth(honest, s.private)
+	//malicious validators settle the private blocks on top of the fork. The
+	//transactions the honest chain settled already are skipped, and once
+	//consensus certifies the private chain it reverts those of the orphaned
+	//blocks and hands the rewards of the others to the private proposers
+	for _, validator := range sim.malValidators {
+		validator.Blockchain = make([]Block, s.fork)
+		copy(validator.Blockchain, s.private)
+	}
+	for _, block := range s.private[s.fork:] {
+		proposer := sim.validatorByAddress(block.Validator)
+		sim.acceptBlock(&Proposal{Block: block, Proposer: proposer, Accepted: true, Recipients: sim.malValidators, Forced: true})
+	}
+	s.private = nil
+	return sim.malValidators[0]
+}
@@ -0,0 +1,16 @@
+package pos
+
+import "testing"
+
+func TestSelfishProposingEarnsMoreThanItsShareOfStake(t *testing.T) {
+	s := testScenario(1)
+	s.NumSlots = 60
+	s.Attack = "selfish_proposing"
+	r := simulate(t, s).Evaluation.Security
+	if r.Selfish.PrivateChainsReleased == 0 || r.Selfish.OrphanedBlocks == 0 {
+		t.Fatalf("no private chain orphaned honest blocks: %+v", *r.Selfish)
+	}
+	if r.MaliciousRewardShare <= r.MaliciousInitialStakeShare {
+		t.Errorf("malicious share of rewards %.2f, not above their share of stake %.2f", r.MaliciousRewardShare, r.MaliciousInitialStakeShare)
+	}
+}
@@ -0,0 +1,121 @@
+package pos
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Under vrf sortition, as in Algorand, no one draws the committee or the
+// proposer. Every validator evaluates a verifiable random function over the
+// round's seed with its own key and selects itself if the output falls below
+// a threshold that grows with its stake, so no one, not even the validator's
+// peers, knows who was selected until the validator reveals its proof along
+// with its vote. Anyone can check the proof against the validator's public
+// key. Among the committee, the validator with the lowest proposer output
+// proposes the block.
+
+// vrfBlockchainTypes lists the protocols that select their committee and
+// proposer by sortition
+var vrfBlockchainTypes = []string{"pos", "slashing"}
+
+// sortitionProof is a validator's proof that it was selected for a role
+type sortitionProof struct {
+	validator *Validator
+	output    [32]byte
+	proof     []byte
+}
+
+// vrfProve evaluates the VRF of validator on input. The proof is the
+// validator's ed25519 signature of input, which only its private key can
+// produce, and the output is the hash of the proof. This is not a real VRF:
+// the signature is only unique for honest signers, as the holder of a key
+// can produce many valid signatures of one input and grind its output down.
+// The simulated validators never do.
+func (curValidator *Validator) vrfProve(input []byte) ([32]byte, []byte) {
+	proof := ed25519.Sign(curValidator.privateKey, input)
+	return sha256.Sum256(proof), proof
+}
+
+// vrfVerify checks that output and proof are the VRF of the owner of
+// publicKey on input
+func vrfVerify(publicKey ed25519.PublicKey, input []byte, output [32]byte, proof []byte) bool {
+	return ed25519.Verify(publicKey, input, proof) && sha256.Sum256(proof) == output
+}
+
+// sortitionInput is what validators evaluate the VRF on for role in the
+// current round
+func (sim *Simulation) sortitionInput(role string) []byte {
+	input := make([]byte, 0, len(sim.sortitionSeed)+8+len(role))
+	input = append(input, sim.sortitionSeed...)
+	input = binary.BigEndian.AppendUint64(input, uint64(sim.roundCount))
+	return append(input, role...)
+}
+
+// selfSelect has validator check whether it is selected for role, where
+// expected validators are selected on average out of total stake
+func (curValidator *Validator) selfSelect(role string, expected float64, total float64) (sortitionProof, bool) {
+	output, proof := curValidator.vrfProve(curValidator.sim.sortitionInput(role))
+	//the output read as a number in [0, 1) is below the chance of a validator with this stake
+	draw := float64(binary.BigEndian.Uint64(output[:8])) / math.Pow(2, 64)
+	chance := 1 - math.Exp(-expected*curValidator.Stake/total)
+	return sortitionProof{validator: curValidator, output: output, proof: proof}, draw < chance
+}
+
+// verifiedProofs keeps the proofs for role that hold, lowest output first
+func (sim *Simulation) verifiedProofs(role string, proofs []sortitionProof) []sortitionProof {
+	input := sim.sortitionInput(role)
+	verified := make([]sortitionProof, 0, len(proofs))
+	for _, proof := range proofs {
+		if vrfVerify(proof.validator.PublicKey, input, proof.output, proof.proof) {
+			verified = append(verified, proof)
+		} else {
+			fmt.Fprintf(sim.out, "Sortition proof of %s rejected\n", proof.validator.Address[:3])
+		}
+	}
+	sort.Slice(verified, func(i, j int) bool {
+		return bytes.Compare(verified[i].output[:], verified[j].output[:]) < 0
+	})
+	return verified
+}
+
+// vrfCommittee moves to the next round's seed and returns the validators
+// that selected themselves for the committee, committeeSize of them on average
+func (sim *Simulation) vrfCommittee(validators []*Validator, committeeSize int) []*Validator {
+	seed := sha256.Sum256(binary.BigEndian.AppendUint64(sim.sortitionSeed, uint64(sim.roundCount)))
+	sim.sortitionSeed = seed[:]
+
+	total := totalStake(validators)
+	proofs := make([]sortitionProof, 0)
+	for _, validator := range validators {
+		if proof, selected := validator.selfSelect("committee", float64(committeeSize), total); selected {
+			proofs = append(proofs, proof)
+		}
+	}
+	committee := make([]*Validator, 0, len(proofs))
+	for _, proof := range sim.verifiedProofs("committee", proofs) {
+		committee = append(committee, proof.validator)
+	}
+	return committee
+}
+
+// vrfProposer returns the committee member with the lowest proposer output,
+// and folds its output into the seed of the next round
+func (sim *Simulation) vrfProposer(committee []*Validator) *Validator {
+	proofs := make([]sortitionProof, 0, len(committee))
+	for _, validator := range committee {
+		output, proof := validator.vrfProve(sim.sortitionInput("proposer"))
+		proofs = append(proofs, sortitionProof{validator: validator, output: output, proof: proof})
+	}
+	proofs = sim.verifiedProofs("proposer", proofs)
+	if len(proofs) == 0 {
+		return nil
+	}
+	seed := sha256.Sum256(append(sim.sortitionSeed, proofs[0].output[:]...))
+	sim.sortitionSeed = seed[:]
+	return proofs[0].validator
+}
@@ -0,0 +1,57 @@
+package pos
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"io"
+	"testing"
+)
+
+func TestVRFProofsOnlyVerifyForTheirKeyAndInput(t *testing.T) {
+	sim := newTestSimulation(t, testScenario(1))
+	alice := sim.newValidator(io.Discard, 500, false)
+	bob := sim.newValidator(io.Discard, 500, false)
+	input := []byte("round 1")
+	output, proof := alice.vrfProve(input)
+	if again, _ := alice.vrfProve(input); again != output {
+		t.Fatal("the same key evaluated the same input to two outputs")
+	}
+
+	tampered := output
+	tampered[0] ^= 1
+	tests := []struct {
+		name      string
+		publicKey ed25519.PublicKey
+		input     []byte
+		output    [32]byte
+		want      bool
+	}{
+		{"own proof", alice.PublicKey, input, output, true},
+		{"other key", bob.PublicKey, input, output, false},
+		{"other input", alice.PublicKey, []byte("round 2"), output, false},
+		{"other output", alice.PublicKey, input, tampered, false},
+	}
+	for _, test := range tests {
+		if got := vrfVerify(test.publicKey, test.input, test.output, proof); got != test.want {
+			t.Errorf("%s: verified %t, want %t", test.name, got, test.want)
+		}
+	}
+}
+
+func TestValidatorKeysAreNotDerivedFromTheAddress(t *testing.T) {
+	keys := func() []*Validator {
+		sim := newTestSimulation(t, testScenario(1))
+		return []*Validator{sim.newValidator(io.Discard, 500, false), sim.newValidator(io.Discard, 500, false)}
+	}
+	first, second := keys(), keys()
+	for i, validator := range first {
+		if !bytes.Equal(validator.PublicKey, second[i].PublicKey) {
+			t.Errorf("validator %d: runs with the same seed drew different keys", i)
+		}
+		seed := sha256.Sum256([]byte(validator.Address))
+		if validator.privateKey.Equal(ed25519.NewKeyFromSeed(seed[:])) {
+			t.Errorf("validator %d: private key follows from the address", i)
+		}
+	}
+}
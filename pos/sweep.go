@@ -0,0 +1,185 @@
+package pos
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"gonum.org/v1/gonum/stat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// SweepSpec lists the values to try for every swept parameter. A parameter
+// left empty keeps the value from Base.
+type SweepSpec struct {
+	Base           Scenario
+	NumMal         []int
+	CommitteeSize  []int
+	DelegateSize   []int
+	BlockchainType []string
+	Attack         []string
+	// Seeded replicas of every combination. Replica i of every combination
+	// uses seed Base.Seed+i, so combinations are compared on the same seeds.
+	Replicas int
+}
+
+// SweepCell aggregates the replicas of one combination of swept values
+type SweepCell struct {
+	Scenario              Scenario     `json:"scenario"`
+	Runs                  []Evaluation `json:"runs"`
+	TotalBlocks           Summary      `json:"totalBlocks"`
+	MaliciousBlocks       Summary      `json:"maliciousBlocks"`
+	TransactionsValidated Summary      `json:"transactionsValidated"`
+}
+
+// Summary is the mean of a metric over replicas with its 95% confidence interval
+type Summary struct {
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stdDev"`
+	CILow  float64 `json:"ciLow"`
+	CIHigh float64 `json:"ciHigh"`
+}
+
+func (s Summary) String() string {
+	return fmt.Sprintf("%.2f [%.2f, %.2f]", s.Mean, s.CILow, s.CIHigh)
+}
+
+// Combinations returns one scenario per combination of swept values, seeded
+// with the base seed
+func (spec SweepSpec) Combinations() []Scenario {
+	combinations := []Scenario{spec.Base}
+	expand := func(n int, apply func(s *Scenario, i int)) {
+		if n == 0 {
+			return
+		}
+		expanded := make([]Scenario, 0, len(combinations)*n)
+		for _, s := range combinations {
+			for i := 0; i < n; i++ {
+				apply(&s, i)
+				expanded = append(expanded, s)
+			}
+		}
+		combinations = expanded
+	}
+	expand(len(spec.BlockchainType), func(s *Scenario, i int) { s.BlockchainType = spec.BlockchainType[i] })
+	expand(len(spec.Attack), func(s *Scenario, i int) { s.Attack = spec.Attack[i] })
+	expand(len(spec.NumMal), func(s *Scenario, i int) { s.NumMal = spec.NumMal[i] })
+	expand(len(spec.CommitteeSize), func(s *Scenario, i int) { s.CommitteeSize = spec.CommitteeSize[i] })
+	expand(len(spec.DelegateSize), func(s *Scenario, i int) { s.DelegateSize = spec.DelegateSize[i] })
+	return combinations
+}
+
+// Sweep runs every replica of every combination with run, on workers
+// goroutines, and aggregates the evaluations of each combination. All
+// combinations are validated before anything runs, and no replica starts
+// once one failed.
+func Sweep(spec SweepSpec, workers int, run func(Scenario) (Evaluation, error)) ([]SweepCell, error) {
+	if spec.Replicas < 1 {
+		return nil, errors.New("replicas must be positive")
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	combinations := spec.Combinations()
+	for _, s := range combinations {
+		err := s.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", describeCombination(s), err)
+		}
+	}
+
+	cells := make([]SweepCell, len(combinations))
+	for i, s := range combinations {
+		cells[i] = SweepCell{Scenario: s, Runs: make([]Evaluation, spec.Replicas)}
+	}
+
+	type job struct {
+		cell    int
+		replica int
+	}
+	jobs := make(chan job)
+	//closed once a replica fails
+	failed := make(chan struct{})
+	var firstErr error
+	var errLock sync.Mutex
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				select {
+				case <-failed:
+					continue
+				default:
+				}
+				replica := cells[j.cell].Scenario
+				replica.Seed += int64(j.replica)
+				evaluation, err := run(replica)
+				if err != nil {
+					errLock.Lock()
+					if firstErr == nil {
+						firstErr = fmt.Errorf("%s, seed %d: %w", describeCombination(replica), replica.Seed, err)
+						close(failed)
+					}
+					errLock.Unlock()
+					continue
+				}
+				cells[j.cell].Runs[j.replica] = evaluation
+			}
+		}()
+	}
+feed:
+	for i := range cells {
+		for r := 0; r < spec.Replicas; r++ {
+			select {
+			case jobs <- job{cell: i, replica: r}:
+			case <-failed:
+				break feed
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	for i := range cells {
+		cells[i].summarise()
+	}
+	return cells, nil
+}
+
+func (cell *SweepCell) summarise() {
+	totalBlocks := make([]float64, len(cell.Runs))
+	maliciousBlocks := make([]float64, len(cell.Runs))
+	transactionsValidated := make([]float64, len(cell.Runs))
+	for i, run := range cell.Runs {
+		totalBlocks[i] = float64(run.TotalBlocks)
+		maliciousBlocks[i] = float64(run.MaliciousBlocks)
+		transactionsValidated[i] = float64(run.TransactionsValidated)
+	}
+	cell.TotalBlocks = summarise(totalBlocks)
+	cell.MaliciousBlocks = summarise(maliciousBlocks)
+	cell.TransactionsValidated = summarise(transactionsValidated)
+}
+
+// summarise computes the mean of values with a Student's t 95% confidence
+// interval, which collapses to the mean for a single replica
+func summarise(values []float64) Summary {
+	if len(values) < 2 {
+		mean := stat.Mean(values, nil)
+		return Summary{Mean: mean, CILow: mean, CIHigh: mean}
+	}
+	mean, stdDev := stat.MeanStdDev(values, nil)
+	t := distuv.StudentsT{Mu: 0, Sigma: 1, Nu: float64(len(values) - 1)}.Quantile(0.975)
+	halfWidth := t * stdDev / math.Sqrt(float64(len(values)))
+	return Summary{Mean: mean, StdDev: stdDev, CILow: mean - halfWidth, CIHigh: mean + halfWidth}
+}
+
+func describeCombination(s Scenario) string {
+	return fmt.Sprintf("%s/%s numMal=%d committeeSize=%d delegateSize=%d", s.BlockchainType, s.Attack, s.NumMal, s.CommitteeSize, s.DelegateSize)
+}
@@ -0,0 +1,59 @@
+package pos
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestSummarise(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   Summary
+	}{
+		{"single replica", []float64{4}, Summary{Mean: 4, CILow: 4, CIHigh: 4}},
+		{"equal replicas", []float64{2, 2, 2}, Summary{Mean: 2, CILow: 2, CIHigh: 2}},
+		// t(0.975, 1) = 12.7062, stdDev = sqrt(2), n = 2
+		{"two replicas", []float64{1, 3}, Summary{Mean: 2, StdDev: math.Sqrt2, CILow: 2 - 12.7062, CIHigh: 2 + 12.7062}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := summarise(test.values)
+			for _, field := range []struct {
+				name      string
+				got, want float64
+			}{
+				{"mean", got.Mean, test.want.Mean},
+				{"stdDev", got.StdDev, test.want.StdDev},
+				{"ciLow", got.CILow, test.want.CILow},
+				{"ciHigh", got.CIHigh, test.want.CIHigh},
+			} {
+				if math.Abs(field.got-field.want) > 1e-3 {
+					t.Errorf("%s = %f, want %f", field.name, field.got, field.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSweepStopsAtTheFirstError(t *testing.T) {
+	base := DefaultScenario()
+	base.Seed = 1
+	spec := SweepSpec{Base: base, Attack: []string{"none", "balance"}, Replicas: 5}
+	runs := 0
+	_, err := Sweep(spec, 1, func(s Scenario) (Evaluation, error) {
+		runs++
+		if s.Seed == 3 {
+			return Evaluation{}, errors.New("replica failed")
+		}
+		return Evaluation{}, nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "replica failed") {
+		t.Fatalf("expected the error of the failed replica, got %v", err)
+	}
+	if runs != 3 {
+		t.Errorf("%d replicas ran, want 3 up to the failed one", runs)
+	}
+}
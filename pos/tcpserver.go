@@ -0,0 +1,262 @@
+package pos
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/joho/godotenv"
+)
+
+// Run serves a new simulation of s on the PORT from .env so validators and
+// users can join with netcat, until it reaches one of its bounds or ctx is
+// cancelled. Slots always follow the wall clock so people typing into netcat
+// can keep up. Metrics of every slot are written to metrics unless it is nil.
+func Run(ctx context.Context, s Scenario, metrics MetricsWriter) (Results, error) {
+	err := godotenv.Load()
+	if err != nil {
+		return Results{}, err
+	}
+
+	s.Clock = "real"
+	sim, err := NewSimulation(s, metrics, os.Stdout)
+	if err != nil {
+		return Results{}, err
+	}
+
+	tcpPort := os.Getenv("PORT")
+
+	// start TCP and serve TCP server
+	server, err := net.Listen("tcp", ":"+tcpPort)
+	if err != nil {
+		return Results{}, err
+	}
+	log.Println("TCP Server Listening on port :", tcpPort)
+
+	return sim.Serve(ctx, server)
+}
+
+// Serve lets validators and users join the simulation through connections
+// accepted from listener. In auto mode the network is first populated with
+// in-process validators and users, exactly as in Simulate. Once the run
+// reaches one of its bounds or ctx is cancelled, the listener and every
+// connection are closed and the final results returned.
+func (sim *Simulation) Serve(ctx context.Context, listener net.Listener) (Results, error) {
+	defer listener.Close()
+
+	//auto create validators and users
+	if sim.scenario.RunType == "auto" {
+		err := sim.createActors(sim.scenario)
+		if err != nil {
+			return Results{}, err
+		}
+	}
+
+	//Accepts connections joining the network
+	var acceptErr error
+	accepting := make(chan struct{})
+	go func() {
+		defer close(accepting)
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				acceptErr = err
+				return
+			}
+			if !sim.trackConnection(conn) {
+				conn.Close()
+				return
+			}
+			go func() {
+				defer sim.untrackConnection(conn)
+				sim.handleConnection(conn)
+			}()
+		}
+	}()
+
+	runCtx, cancel := context.WithCancel(ctx)
+	go func() {
+		//stop the engine if the listener fails
+		<-accepting
+		cancel()
+	}()
+	sim.engine.run(runCtx, func() bool {
+		return sim.stopReason() != ""
+	})
+	cancel()
+
+	stopping := sim.stopReason() != "" || ctx.Err() != nil
+	listener.Close()
+	<-accepting
+	sim.closeConnections()
+	sim.handlers.Wait()
+
+	results, err := sim.finish(ctx)
+	if !stopping && err == nil {
+		err = acceptErr
+	}
+	return results, err
+}
+
+// trackConnection registers conn so it is closed when the run stops. It
+// reports false once the run is stopping.
+func (sim *Simulation) trackConnection(conn net.Conn) bool {
+	sim.connsLock.Lock()
+	defer sim.connsLock.Unlock()
+	if sim.conns == nil {
+		return false
+	}
+	sim.conns[conn] = true
+	sim.handlers.Add(1)
+	return true
+}
+
+func (sim *Simulation) untrackConnection(conn net.Conn) {
+	sim.connsLock.Lock()
+	if sim.conns != nil {
+		delete(sim.conns, conn)
+	}
+	sim.connsLock.Unlock()
+	sim.handlers.Done()
+}
+
+// closeConnections disconnects every validator and user that joined over TCP
+// and refuses new ones
+func (sim *Simulation) closeConnections() {
+	sim.connsLock.Lock()
+	defer sim.connsLock.Unlock()
+	for conn := range sim.conns {
+		conn.Close()
+	}
+	sim.conns = nil
+}
+
+func (sim *Simulation) handleConnection(conn net.Conn) {
+	defer conn.Close()
+
+	//Determine user or validator connection
+	io.WriteString(conn, "Is this node a user or validator (u/v)\n")
+	scannedType := bufio.NewScanner(conn)
+	for scannedType.Scan() {
+		if scannedType.Text() == "u" {
+			sim.handleUserConnection(conn, scannedType)
+		} else if scannedType.Text() == "v" {
+			sim.handleValidatorConnection(conn, scannedType)
+		} else {
+			fmt.Fprintf(sim.out, "%s is not a valid response\n Please enter 'u' or 'v' ", scannedType.Text())
+		}
+		break
+	}
+}
+
+func (sim *Simulation) handleValidatorConnection(conn net.Conn, scanner *bufio.Scanner) {
+	//Enter initial stake and whether or not validator is malicious
+	io.WriteString(conn, "Enter token stake:\n")
+	if !scanner.Scan() {
+		return
+	}
+	balance, err := strconv.ParseFloat(scanner.Text(), 64)
+	if err != nil {
+		io.WriteString(conn, scanner.Text()+" not a number")
+		return
+	}
+
+	io.WriteString(conn, "Is this node malicious (y/n)\n")
+	if !scanner.Scan() {
+		return
+	}
+	if scanner.Text() != "y" && scanner.Text() != "n" {
+		io.WriteString(conn, scanner.Text()+" is not a valid response\n Please enter 'y' or 'n' ")
+		return
+	}
+	isMal := scanner.Text() == "y"
+
+	joined := sim.engine.post(func() {
+		sim.newValidator(conn, balance, isMal)
+	})
+	if !joined {
+		return
+	}
+
+	//keep the connection open until the validator leaves
+	for scanner.Scan() {
+	}
+}
+
+func (sim *Simulation) handleUserConnection(conn net.Conn, scanner *bufio.Scanner) {
+	//Enter initial balance
+	io.WriteString(conn, "Enter initial token balance:\n")
+	if !scanner.Scan() {
+		return
+	}
+	balance, err := strconv.ParseFloat(scanner.Text(), 64)
+	if err != nil {
+		io.WriteString(conn, scanner.Text()+" not a number")
+		return
+	}
+
+	//Enter name
+	io.WriteString(conn, "Enter user name:\n")
+	var curUser *User
+	for curUser == nil {
+		if !scanner.Scan() {
+			return
+		}
+		name := scanner.Text()
+		joined := sim.engine.post(func() {
+			if _, ok := sim.users[name]; ok {
+				fmt.Fprintf(sim.out, "Name: %s already taken: \n", name)
+				return
+			}
+			curUser, err = sim.newUser(conn, name, balance)
+		})
+		if !joined {
+			return
+		}
+		if err != nil {
+			fmt.Fprintln(sim.out, "Error generating private key:", err)
+			return
+		}
+	}
+
+	for {
+		io.WriteString(conn, "Starting new transaction\n")
+		io.WriteString(conn, "Enter receiver name:\n")
+		if !scanner.Scan() {
+			return
+		}
+		receiverName := scanner.Text()
+
+		io.WriteString(conn, "Enter transaction amount:\n")
+		if !scanner.Scan() {
+			return
+		}
+		amount, err := strconv.ParseFloat(scanner.Text(), 64)
+		if err != nil {
+			io.WriteString(conn, scanner.Text()+" not a number")
+			return
+		}
+
+		io.WriteString(conn, "Enter transaction reward:\n")
+		if !scanner.Scan() {
+			return
+		}
+		reward, err := strconv.ParseFloat(scanner.Text(), 64)
+		if err != nil {
+			io.WriteString(conn, scanner.Text()+" not a number")
+			return
+		}
+
+		sent := sim.engine.post(func() {
+			curUser.sendTransaction(receiverName, amount, reward)
+		})
+		if !sent {
+			return
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package pos
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// serve runs s in manual mode with the validators of stakes joining before
+// the first slot and nobody connecting over TCP
+func serve(t *testing.T, s Scenario, stakes ...float64) Results {
+	t.Helper()
+	s.RunType = "manual"
+	sim := newTestSimulation(t, s)
+	for _, stake := range stakes {
+		sim.newValidator(io.Discard, stake, false)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	results, err := sim.Serve(context.Background(), listener)
+	if err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	return results
+}
+
+func TestManualRunWithFewerValidatorsThanDelegates(t *testing.T) {
+	for _, blockchainType := range []string{"reputation", "dpos", "npos"} {
+		t.Run(blockchainType, func(t *testing.T) {
+			s := testScenario(1)
+			s.BlockchainType = blockchainType
+			s.DelegateSize = 3
+			results := serve(t, s, 500)
+			if results.Evaluation.TotalBlocks == 0 {
+				t.Errorf("the only validator proposed no blocks: %+v", results.Evaluation)
+			}
+		})
+	}
+}
+
+func TestServeLetsValidatorsJoinOverTCP(t *testing.T) {
+	s := testScenario(1)
+	s.RunType = "manual"
+	s.Clock = "real"
+	s.SlotDuration = Duration(10 * time.Millisecond)
+	s.NumSlots = 50
+	sim := newTestSimulation(t, s)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	//a malicious validator with a stake of 500
+	if _, err := io.WriteString(conn, "v\n500\ny\n"); err != nil {
+		t.Fatalf("joining: %v", err)
+	}
+
+	results, err := sim.Serve(context.Background(), listener)
+	if err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	security := results.Evaluation.Security
+	if security.MaliciousValidators != 1 || security.MaliciousFinalStake != 500 {
+		t.Errorf("the validator did not join: %+v", security)
+	}
+}
@@ -0,0 +1,217 @@
+package pos
+
+import "fmt"
+
+// tendermintProtocol is round-based BFT consensus in the style of
+// Tendermint. Every validator votes, and every time slot is one round: the
+// proposer, rotated in proportion to stake, proposes a block and validators
+// prevote for it. If more than 2/3 of the stake prevoted for the block,
+// validators precommit to it and lock on it, and once more than 2/3 of the
+// stake precommitted the block is final. Otherwise the round times out and
+// the next slot starts the next round at the same height, where locked
+// validators only prevote for the block they locked on and the proposer
+// proposes again the last block that gathered 2/3 of the prevotes.
+// Validators caught signing two blocks in one round are slashed.
+type tendermintProtocol struct {
+	baseProtocol
+	// Accumulated proposer priority of every validator, by address
+	priorities map[string]float64
+	// Round at the current height, counting timeouts since the last commit
+	round int
+	// Block every validator locked on, by address
+	locks map[string]Block
+	// Last block that gathered 2/3 of the prevotes at the current height
+	validBlock *Block
+}
+
+func newTendermintProtocol() *tendermintProtocol {
+	return &tendermintProtocol{
+		priorities: make(map[string]float64),
+		locks:      make(map[string]Block),
+	}
+}
+
+func (p *tendermintProtocol) SelectCommittee(sim *Simulation) []*Validator {
+	committee := make([]*Validator, len(sim.validators))
+	copy(committee, sim.validators)
+	return committee
+}
+
+// SelectProposer raises the priority of every validator by its stake and
+// picks the highest, whose priority then drops by the total stake, so each
+// validator proposes in proportion to its stake
+func (p *tendermintProtocol) SelectProposer(sim *Simulation, committee []*Validator) *Validator {
+	var proposer *Validator
+	for _, validator := range committee {
+		p.priorities[validator.Address] += validator.Stake
+		if proposer == nil || p.priorities[validator.Address] > p.priorities[proposer.Address] {
+			proposer = validator
+		}
+	}
+	if proposer == nil {
+		return nil
+	}
+	p.priorities[proposer.Address] -= totalStake(committee)
+	fmt.Fprintf(sim.out, "Round %d\n", p.round)
+	return proposer
+}
+
+func (p *tendermintProtocol) ProposeBlock(sim *Simulation, proposer *Validator) (Block, error) {
+	tip := proposer.Blockchain[len(proposer.Blockchain)-1]
+	if p.validBlock != nil && p.validBlock.PrevHash == tip.Hash {
+		fmt.Fprintf(sim.out, "Proposing block %d again\n", p.validBlock.Index)
+		return *p.validBlock, nil
+	}
+	return sim.generateBlock(proposer)
+}
+
+// CollectVotes runs the prevote and precommit steps of a round
+func (p *tendermintProtocol) CollectVotes(sim *Simulation, committee []*Validator, msg interface{}) Votes {
+	var blocks []Block
+	switch msg := msg.(type) {
+	case ValidateBlockMessage:
+		blocks = []Block{msg.newBlock}
+	case ValidateShortAttackBlockMessage:
+		blocks = []Block{msg.newBlock, msg.newBlockTwo}
+	}
+	total := totalStake(committee)
+	doubleSigners := make(map[*Validator]bool)
+	if len(blocks) > 1 {
+		doubleSigners[sim.proposer] = true
+	}
+
+	//prevote for valid blocks, honest validators only for one and only for the block they locked on
+	prevoteStake := make([]float64, len(blocks))
+	for _, validator := range committee {
+		prevotes := validationReplies(validator.receive(msg))
+		signed := 0
+		for i, block := range blocks {
+			if i >= len(prevotes) || !prevotes[i] {
+				continue
+			}
+			if !validator.IsMalicious {
+				lock, locked := p.locks[validator.Address]
+				if signed > 0 || locked && lock.Index == block.Index && lock.Hash != block.Hash {
+					continue
+				}
+			}
+			prevoteStake[i] += validator.Stake
+			signed++
+		}
+		if signed > 1 {
+			doubleSigners[validator] = true
+		}
+	}
+	polka := make([]bool, len(blocks))
+	for i := len(blocks) - 1; i >= 0; i-- {
+		polka[i] = prevoteStake[i] > 2*total/3
+		if polka[i] {
+			p.validBlock = &blocks[i]
+		}
+	}
+
+	//precommit to blocks with 2/3 of the prevotes and lock on them
+	votes := Votes{Ballots: make(map[string]bool)}
+	precommitStake := make([]float64, len(blocks))
+	for _, validator := range committee {
+		signed := 0
+		for i, block := range blocks {
+			precommit := polka[i] && signed == 0
+			if validator.IsMalicious {
+				precommit = sim.attack.OnVote(sim, validator, block, polka[i])
+			}
+			if i == 0 {
+				votes.Ballots[validator.Address] = precommit
+			}
+			if !precommit {
+				continue
+			}
+			if !validator.IsMalicious {
+				p.locks[validator.Address] = block
+			}
+			precommitStake[i] += validator.Stake
+			signed++
+			if i == 0 {
+				votes.Valid++
+			} else {
+				votes.ValidTwo++
+			}
+		}
+		if signed > 1 {
+			doubleSigners[validator] = true
+		}
+	}
+	votes.Invalid = len(committee) - votes.Valid
+	votes.Accepted = precommitStake[0] > 2*total/3
+	if len(blocks) > 1 {
+		votes.InvalidTwo = len(committee) - votes.ValidTwo
+		votes.AcceptedTwo = precommitStake[1] > 2*total/3
+	}
+	fmt.Fprintf(sim.out, "Prevotes %.0f%%, precommits %.0f%% of stake\n", 100*prevoteStake[0]/total, 100*precommitStake[0]/total)
+
+	//slash the evidence of double signing
+	for _, validator := range committee {
+		if doubleSigners[validator] {
+			fmt.Fprintf(sim.out, "Validator %s slashed for signing two blocks\n", validator.Address[:3])
+			validator.Stake *= sim.scenario.SlashRatio
+		}
+	}
+
+	if votes.Accepted || votes.AcceptedTwo {
+		p.round = 0
+		p.locks = make(map[string]Block)
+		p.validBlock = nil
+	} else {
+		fmt.Fprintln(sim.out, "Round timed out")
+		p.round++
+		sim.security.Liveness.recordTimeout(p.round)
+	}
+	return votes
+}
+
+// RewardProposer leaves proposers with their transaction rewards only
+func (p *tendermintProtocol) RewardProposer(sim *Simulation, proposer *Validator) {}
+
+// PunishProposer does nothing, a round without a commit just times out
+func (p *tendermintProtocol) PunishProposer(sim *Simulation, proposer *Validator) {}
+
+// SettleVotes does nothing, voting nil is not an offence
+func (p *tendermintProtocol) SettleVotes(sim *Simulation, committee []*Validator, votes Votes, accepted bool) {
+}
+
+// PunishForkProposer slashes the proposer of a fork, which breaks finality
+func (p *tendermintProtocol) PunishForkProposer(sim *Simulation, proposer *Validator) {
+	proposer.Stake *= sim.scenario.SlashRatio
+}
+
+// validationReplies lists the blocks a validator found valid in its reply
+func validationReplies(reply interface{}) []bool {
+	switch reply := reply.(type) {
+	case ValidationStatusMessage:
+		return []bool{reply.isValid}
+	case ValidationShortAttackStatusMessage:
+		return []bool{reply.isValid, reply.isValidTwo}
+	default:
+		return nil
+	}
+}
+
+// totalStake adds up the stake of validators
+func totalStake(validators []*Validator) float64 {
+	total := 0.0
+	for _, validator := range validators {
+		total += validator.Stake
+	}
+	return total
+}
+
+// validatorByAddress returns the validator with address, or nil if there is
+// none
+func (sim *Simulation) validatorByAddress(address string) *Validator {
+	for _, validator := range sim.validators {
+		if validator.Address == address {
+			return validator
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package pos
+
+import (
+	"io"
+	"testing"
+)
+
+func TestTendermintRewardsTheBuilderOfABlockProposedAgain(t *testing.T) {
+	s := testScenario(1)
+	s.BlockchainType = "tendermint"
+	s.Attack = "none"
+	s.NumUsers = 0
+	s.NumMal = 0
+	sim, err := NewSimulation(s, nil, io.Discard)
+	if err != nil {
+		t.Fatalf("NewSimulation: %v", err)
+	}
+	if err := sim.createActors(s); err != nil {
+		t.Fatalf("createActors: %v", err)
+	}
+	sender, err := sim.newUser(io.Discard, "alice", 100)
+	if err != nil {
+		t.Fatalf("newUser: %v", err)
+	}
+	if _, err := sim.newUser(io.Discard, "bob", 100); err != nil {
+		t.Fatalf("newUser: %v", err)
+	}
+	sender.sendTransaction("bob", 10, 2)
+
+	//the validator with the least stake is the last to propose
+	builder := sim.validators[0]
+	for _, validator := range sim.validators {
+		if validator.Stake < builder.Stake {
+			builder = validator
+		}
+	}
+	block, err := sim.generateBlock(builder)
+	if err != nil {
+		t.Fatalf("generateBlock: %v", err)
+	}
+	//as if the block gathered 2/3 of the prevotes in an earlier round
+	sim.protocol.(*tendermintProtocol).validBlock = &block
+	stake := builder.Stake
+
+	sim.nextTimeSlot()
+	if sim.proposer == builder {
+		t.Fatal("the builder proposed its block itself")
+	}
+	chain := sim.proposer.Blockchain
+	if chain[len(chain)-1].Hash != block.Hash {
+		t.Fatalf("block proposed again was not committed: %s", chainString(chain))
+	}
+	if builder.Stake != stake+2 {
+		t.Errorf("builder's stake went from %f to %f, want the reward of 2 on top", stake, builder.Stake)
+	}
+	if builder.blockSuccessCount != 1 || sim.proposer.blockSuccessCount != 0 {
+		t.Errorf("block credited to the proposer of the round")
+	}
+}
@@ -1,31 +1,37 @@
 package pos
 
 import (
-	"bufio"
-	"crypto"
-	"crypto/rand"
-	"crypto/rsa"
+	"crypto/ed25519"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"io"
-	mathrand "math/rand"
-	"net"
-	"strconv"
+	"math"
+	"sort"
 	"strings"
 	"sync"
-	"time"
+
+	"golang.org/x/exp/slices"
 )
 
 type User struct {
-	conn        net.Conn
-	commChannel chan interface{}
-	Name        string
-	Address     string
-	Balance     float64
-	PublicKey   *rsa.PublicKey
-	privateKey  *rsa.PrivateKey
-	userLock    sync.Mutex
+	sim        *Simulation
+	out        io.Writer
+	Name       string
+	Address    string
+	Balance    float64
+	PublicKey  ed25519.PublicKey
+	privateKey ed25519.PrivateKey
+	userLock   sync.Mutex
+	// Addresses of the validators the user votes for under dpos or nominates
+	// under npos, and the tokens locked behind them
+	nominations []string
+	locked      float64
+	// Whether a censorship attacker targets the user, and its transactions
+	// sent, included in accepted blocks and the time slots they waited
+	targeted       bool
+	sent, included int
+	inclusionSlots int
 }
 
 type Transaction struct {
@@ -35,18 +41,11 @@ type Transaction struct {
 	Signature string
 	Amount    float64
 	Reward    float64
+	// Addresses of the validators a vote transaction locks Amount behind, one
+	// under dpos and up to maxNominations under npos, empty for transfers
+	Candidates []string
 }
 
-var transactionID = 0
-
-var userID = 0
-
-var usersSliceLock = &sync.Mutex{}
-
-var userIDLock = &sync.Mutex{}
-
-var transactionIDLock = &sync.Mutex{}
-
 func generateTransaction(index int, sender *User, receiver *User, amount float64, reward float64) Transaction {
 	transaction := Transaction{
 		ID:       index,
@@ -59,186 +58,198 @@ func generateTransaction(index int, sender *User, receiver *User, amount float64
 	return transaction
 }
 
-func signTransaction(t *Transaction, privateKey *rsa.PrivateKey) error {
-	// Concatenate the transaction data into a single string
-	data := fmt.Sprintf("%d%p%p%f%f", t.ID, t.Sender, t.Receiver, t.Amount, t.Reward)
+func generateVoteTransaction(index int, sender *User, candidates []string, amount float64, reward float64) Transaction {
+	transaction := Transaction{
+		ID:         index,
+		Sender:     sender,
+		Amount:     amount,
+		Reward:     reward,
+		Candidates: candidates,
+	}
+	signTransaction(&transaction, sender.privateKey)
+	return transaction
+}
+
+// recipient returns the address of the receiver of a transfer, or the
+// addresses of the candidates of a vote
+func (t Transaction) recipient() string {
+	if t.Receiver != nil {
+		return t.Receiver.Address
+	}
+	return strings.Join(t.Candidates, "")
+}
+
+// transactionData concatenates the signed transaction fields into a single string
+func transactionData(t Transaction) string {
+	data := fmt.Sprintf("%d%s%s%f%f", t.ID, t.Sender.Address, t.recipient(), t.Amount, t.Reward)
+	if len(t.Candidates) > 0 {
+		data += "vote"
+	}
+	return data
+}
 
+func signTransaction(t *Transaction, privateKey ed25519.PrivateKey) {
 	// Hash the data using SHA256
-	hash := sha256.Sum256([]byte(data))
+	hash := sha256.Sum256([]byte(transactionData(*t)))
 
 	// Sign the hashed data using the private key
-	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, hash[:])
-	if err != nil {
-		return err
-	}
+	signature := ed25519.Sign(privateKey, hash[:])
 
-	// Encode the signature as a base64 string
+	// Encode the signature as a hex string
 	t.Signature = hex.EncodeToString(signature)
-
-	return nil
 }
 
-func handleUserConnection(conn net.Conn, runType string) {
-	defer conn.Close()
+// newUser instantiates a user that reports its activity to out and registers
+// it with the network
+func (sim *Simulation) newUser(out io.Writer, name string, balance float64) (*User, error) {
+	address := sim.randomAddress()
 
-	//Enter initial stake and whether or not validator is malicious
-	io.WriteString(conn, "Enter initial token balance:\n")
-	scannedBalance := bufio.NewScanner(conn)
-	if runType == "auto" {
-		randomBalance := 0.0
-		mathrand.Seed(time.Now().UnixNano())
-		randomBalance = mathrand.Float64()*1000 + 10
-		randomBalanceString := fmt.Sprintf("%f", randomBalance)
-		scannedBalance = bufio.NewScanner(strings.NewReader(randomBalanceString))
-	}
-	balance := 0.0
-	var err error
-	for scannedBalance.Scan() {
-		balance, err = strconv.ParseFloat(scannedBalance.Text(), 64)
-		if err != nil {
-			io.WriteString(conn, scannedBalance.Text()+" not a number")
-			return
-		}
-		break
+	//derive the key pair from the run's randomness so signatures are reproducible
+	seed := make([]byte, ed25519.SeedSize)
+	_, err := sim.rng.Read(seed)
+	if err != nil {
+		return nil, err
 	}
+	privateKey := ed25519.NewKeyFromSeed(seed)
 
-	//Enter name
-	io.WriteString(conn, "Enter user name:\n")
-	scannedName := bufio.NewScanner(conn)
-	name := ""
-	if runType == "auto" {
-		userIDLock.Lock()
-		name = fmt.Sprintf("user%d", userID)
-		userID++
-		userIDLock.Unlock()
-		scannedName = bufio.NewScanner(strings.NewReader(name))
-	}
-	for scannedName.Scan() {
-		name = scannedName.Text()
-		if _, ok := users[name]; ok {
-			fmt.Printf("Name: %s already taken: \n", name)
-		} else {
-			break
-		}
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+
+	//Instantiate new user
+	curUser := &User{
+		sim:        sim,
+		out:        out,
+		Name:       name,
+		Address:    address,
+		Balance:    float64(balance),
+		privateKey: privateKey,
+		PublicKey:  publicKey,
+		userLock:   sync.Mutex{},
 	}
 
-	//Calculate address based on time
-	t := time.Now()
-	address := calculateHash(t.String())
+	sim.usersSliceLock.Lock()
+	curUser.targeted = hasAttack(sim.scenario.Attack, "censorship") && len(sim.users) < sim.scenario.CensoredUsers
+	sim.users[name] = curUser
+	sim.usersSliceLock.Unlock()
 
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		fmt.Println("Error generating private key:", err)
-		return
-	}
+	fmt.Fprintf(sim.out, "new user count: %d\n", len(sim.users))
+	return curUser, nil
+}
 
-	publicKey := &privateKey.PublicKey
+// newAutoUser instantiates a user with a generated name and a random balance
+func (sim *Simulation) newAutoUser(out io.Writer) (*User, error) {
+	sim.userIDLock.Lock()
+	name := fmt.Sprintf("user%d", sim.userID)
+	sim.userID++
+	sim.userIDLock.Unlock()
 
-	//Instantiate new validator
-	curUser := &User{
-		conn:        conn,
-		commChannel: make(chan interface{}),
-		Name:        name,
-		Address:     address,
-		Balance:     float64(balance),
-		privateKey:  privateKey,
-		PublicKey:   publicKey,
-		userLock:    sync.Mutex{},
-	}
+	balance := sim.scenario.BalanceDistribution.sample(sim.rng)
+	return sim.newUser(out, name, balance)
+}
 
-	users[name] = curUser
+// sendTransaction signs a new transaction to receiverName and broadcasts it
+// to all validators
+func (curUser *User) sendTransaction(receiverName string, amount float64, reward float64) {
+	curTransactionID := curUser.sim.newTransactionID()
 
-	fmt.Printf("new user count: %d\n", len(users))
+	curTransaction := generateTransaction(curTransactionID, curUser.sim.users[curUser.Name], curUser.sim.users[receiverName], amount, reward)
+	curUser.broadcastTransaction(curTransaction)
+}
 
-	for {
-		io.WriteString(conn, "Starting new transaction\n")
-		io.WriteString(conn, "Enter receiver name:\n")
-		scannedReceiver := bufio.NewScanner(conn)
-		receiverName := ""
+// sendVote signs a vote transaction locking amount behind candidates and
+// broadcasts it to all validators
+func (curUser *User) sendVote(candidates []*Validator, amount float64, reward float64) {
+	curTransactionID := curUser.sim.newTransactionID()
 
-		if runType == "auto" {
-			usersSliceLock.Lock()
-			mathrand.Seed(time.Now().UnixNano())
-			randomIndex := 0
-			if len(users)-1 > 0 {
-				randomIndex = mathrand.Intn(len(users) - 1)
-			}
-			counter := 0
-			randomUser := ""
-			for userName := range users {
-				if counter == randomIndex {
-					randomUser = userName
-					break
-				}
-				counter++
-			}
-			scannedReceiver = bufio.NewScanner(strings.NewReader(randomUser))
-			usersSliceLock.Unlock()
-		}
+	curTransaction := generateVoteTransaction(curTransactionID, curUser, addresses(candidates), amount, reward)
+	curUser.broadcastTransaction(curTransaction)
+}
 
-		for scannedReceiver.Scan() {
-			receiverName = scannedReceiver.Text()
-			break
-		}
+// newTransactionID returns the ID of the next transaction
+func (sim *Simulation) newTransactionID() int {
+	sim.transactionIDLock.Lock()
+	defer sim.transactionIDLock.Unlock()
+	sim.transactionID++
+	return sim.transactionID - 1
+}
 
-		io.WriteString(conn, "Enter transaction amount:\n")
-		scannedAmount := bufio.NewScanner(conn)
-		if runType == "auto" {
-			randomAmount := 0.0
-			mathrand.Seed(time.Now().UnixNano())
-			randomAmount = mathrand.Float64()*100 + 1
-			randomAmountString := fmt.Sprintf("%f", randomAmount)
-			scannedAmount = bufio.NewScanner(strings.NewReader(randomAmountString))
-		}
-		amount := 0.0
-		for scannedAmount.Scan() {
-			amount, err = strconv.ParseFloat(scannedAmount.Text(), 64)
-			if err != nil {
-				io.WriteString(conn, scannedAmount.Text()+" not a number")
-				return
-			}
-			break
-		}
+// broadcastTransaction sends curTransaction to all validators
+func (curUser *User) broadcastTransaction(curTransaction Transaction) {
+	//Broadcast current transaction to all validators
+	curUser.sim.validatorsSliceLock.Lock()
+	validatorsCopy := curUser.sim.validators
+	curUser.sim.validatorsSliceLock.Unlock()
+	curUser.broadcastTransactionTo(curTransaction, validatorsCopy)
+}
 
-		io.WriteString(conn, "Enter transaction reward:\n")
-		scannedReward := bufio.NewScanner(conn)
-		if runType == "auto" {
-			randomReward := 0.0
-			mathrand.Seed(time.Now().UnixNano())
-			randomReward = mathrand.Float64()*5 + 0
-			randomRewardString := fmt.Sprintf("%f", randomReward)
-			scannedReward = bufio.NewScanner(strings.NewReader(randomRewardString))
-		}
-		reward := 0.0
-		for scannedReward.Scan() {
-			reward, err = strconv.ParseFloat(scannedReward.Text(), 64)
-			if err != nil {
-				io.WriteString(conn, scannedReward.Text()+" not a number")
-				return
-			}
-			break
+// broadcastTransactionTo sends curTransaction to validators only
+func (curUser *User) broadcastTransactionTo(curTransaction Transaction, validators []*Validator) {
+	curUser.sim.sentAt[curTransaction.ID] = curUser.sim.roundCount
+	curUser.sent++
+	transactionString := fmt.Sprintf("Sent transaction %d\n", curTransaction.ID)
+	io.WriteString(curUser.out, transactionString)
+	for _, validator := range validators {
+		msg := NewTransactionMessage{
+			transaction: curTransaction,
 		}
+		validator.receiveTransaction(msg)
+	}
+}
 
-		transactionIDLock.Lock()
-		curTransactionID := transactionID
-		transactionID++
-		transactionIDLock.Unlock()
-
-		curTransaction := generateTransaction(curTransactionID, users[curUser.Name], users[receiverName], amount, reward)
-
-		//Broadcast current transaction to all validators
-		validatorsSliceLock.Lock()
-		validatorsCopy := validators
-		validatorsSliceLock.Unlock()
-		transactionString := fmt.Sprintf("Sent transaction %d\n", curTransaction.ID)
-		io.WriteString(conn, transactionString)
-		for _, validator := range validatorsCopy {
-			msg := NewTransactionMessage{
-				transaction: curTransaction,
+// sendRandomTransaction sends a random amount to a random user, or under dpos
+// and npos sometimes votes instead
+func (curUser *User) sendRandomTransaction() {
+	voting := curUser.sim.blockchainType == "dpos" || curUser.sim.blockchainType == "npos"
+	if voting && curUser.sim.rng.Float64() < curUser.sim.scenario.VoteRate {
+		curUser.sendRandomVote()
+		return
+	}
+	curUser.sim.usersSliceLock.Lock()
+	randomIndex := 0
+	if len(curUser.sim.users)-1 > 0 {
+		randomIndex = curUser.sim.rng.Intn(len(curUser.sim.users) - 1)
+	}
+	//sort names so the receiver does not depend on map order
+	userNames := make([]string, 0, len(curUser.sim.users))
+	for userName := range curUser.sim.users {
+		userNames = append(userNames, userName)
+	}
+	sort.Strings(userNames)
+	receiverName := ""
+	if len(userNames) > 0 {
+		receiverName = userNames[randomIndex]
+	}
+	curUser.sim.usersSliceLock.Unlock()
+
+	amount := curUser.sim.rng.Float64()*100 + 1
+	reward := curUser.sim.rng.Float64()*5 + 0
+	curUser.sendTransaction(receiverName, amount, reward)
+}
+
+// sendRandomVote locks a random share of the user's tokens behind a random
+// validator, or under npos behind up to maxNominations random validators,
+// unless an attack talks it into voting for others
+func (curUser *User) sendRandomVote() {
+	sim := curUser.sim
+	if len(sim.validators) == 0 {
+		return
+	}
+	var candidates []*Validator
+	if sim.blockchainType == "npos" {
+		for _, i := range sim.rng.Perm(len(sim.validators)) {
+			if len(candidates) == sim.scenario.MaxNominations {
+				break
 			}
-			validator.transactionChannel <- msg
+			candidates = append(candidates, sim.validators[i])
 		}
-		time.Sleep(1 * time.Second)
+		//keep nominations in the order validators joined
+		sort.Slice(candidates, func(i, j int) bool {
+			return slices.Index(sim.validators, candidates[i]) < slices.Index(sim.validators, candidates[j])
+		})
+	} else {
+		candidates = []*Validator{sim.validators[sim.rng.Intn(len(sim.validators))]}
 	}
-
+	reward := sim.rng.Float64() * 5
+	amount := math.Max(0, curUser.Balance-reward) * sim.rng.Float64()
+	candidates = sim.attack.OnDelegateVote(sim, curUser, candidates, amount)
+	curUser.sendVote(candidates, amount, reward)
 }
@@ -1,46 +1,44 @@
 package pos
 
 import (
-	"bufio"
-	"crypto"
-	"crypto/rsa"
+	"crypto/ed25519"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
-	"math/rand"
-	"net"
 	"sort"
-	"strconv"
-	"strings"
 	"sync"
-	"time"
 )
 
 type Validator struct {
-	conn                       net.Conn
-	incomingChannel            chan interface{}
-	outgoingChannel            chan interface{}
-	transactionChannel         chan NewTransactionMessage
-	delegateVoteRequestChannel chan DelegateVoteRequestMessage
-	delegateVoteChannel        chan DelegateVoteMessage
-	Address                    string
-	Stake                      float64
-	unconfirmedTransactions    map[int]Transaction
-	confirmedTransactions      map[int]bool
-	IsMalicious                bool
-	validatorLock              sync.Mutex
-	transactionPoolLock        sync.Mutex
-	committeeCount             int
-	proposerCount              int
-	blockSuccessCount          int
-	reputation                 float64
-	Blockchain                 []Block
+	sim                     *Simulation
+	out                     io.Writer
+	Address                 string
+	PublicKey               ed25519.PublicKey
+	privateKey              ed25519.PrivateKey
+	Stake                   float64
+	initialStake            float64
+	unconfirmedTransactions map[int]Transaction
+	confirmedTransactions   map[int]bool
+	IsMalicious             bool
+	validatorLock           sync.Mutex
+	transactionPoolLock     sync.Mutex
+	committeeCount          int
+	proposerCount           int
+	blockSuccessCount       int
+	reputation              float64
+	Blockchain              []Block
 }
 
 // generateBlock creates a new block using previous block's hash
-func generateBlock(proposer *Validator) (Block, error) {
+func (sim *Simulation) generateBlock(proposer *Validator) (Block, error) {
+	return sim.generateBlockOn(proposer, proposer.Blockchain[len(proposer.Blockchain)-1], nil)
+}
+
+// generateBlockOn creates a new block extending oldBlock, leaving out the
+// transactions in skip
+func (sim *Simulation) generateBlockOn(proposer *Validator, oldBlock Block, skip map[int]bool) (Block, error) {
 
 	var newBlock Block
 
@@ -52,7 +50,23 @@ func generateBlock(proposer *Validator) (Block, error) {
 		if transactionsSize > 5 {
 			transactionsSize = 5
 		}
+		//take the transactions paying the highest reward first, the oldest of
+		//equal ones, so blocks do not depend on map order
+		ids := make([]int, 0, len(proposer.unconfirmedTransactions))
 		for id := range proposer.unconfirmedTransactions {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool {
+			first, second := proposer.unconfirmedTransactions[ids[i]], proposer.unconfirmedTransactions[ids[j]]
+			if first.Reward != second.Reward {
+				return first.Reward > second.Reward
+			}
+			return ids[i] < ids[j]
+		})
+		for _, id := range ids {
+			if skip[id] || !sim.attack.OnTransactionSelected(sim, proposer, proposer.unconfirmedTransactions[id]) {
+				continue
+			}
 			transactions = append(transactions, proposer.unconfirmedTransactions[id])
 			if transactionsSize == len(transactions) {
 				break
@@ -67,60 +81,39 @@ func generateBlock(proposer *Validator) (Block, error) {
 
 	//set block information
 
-	t := time.Now()
-	oldBlock := proposer.Blockchain[len(proposer.Blockchain)-1]
+	t := sim.clock.Now()
 	newBlock.Index = oldBlock.Index + 1
 	newBlock.Timestamp = t.String()
 	newBlock.PrevHash = oldBlock.Hash
 	newBlock.Validator = proposer.Address
 	newBlock.Transactions = transactions
 	newBlock.Hash = calculateBlockHash(newBlock)
+	signBlock(&newBlock, proposer.privateKey)
 	newBlock.IsMalicious = proposer.IsMalicious
 
 	return newBlock, nil
 }
 
-func balanceAttackIsBlockValid(newBlock Block, malVote bool, malValidator bool) bool {
-	oldBlock := proposer.Blockchain[len(proposer.Blockchain)-1]
-
-	// logic to attempt to balance forks of chain if validator is malicious
-	if malValidator{
-		return malVote
-	}
+func (sim *Simulation) isBlockValid(newBlock Block) bool {
+	oldBlock := sim.proposer.Blockchain[len(sim.proposer.Blockchain)-1]
 
 	if oldBlock.Index+1 != newBlock.Index {
-		fmt.Println("old block is not the previous block")
+		fmt.Fprintln(sim.out, "old block is not the previous block")
 		return false
 	}
 
 	if oldBlock.Hash != newBlock.PrevHash {
-		fmt.Println("old block hash does not match with the previous hash")
+		fmt.Fprintln(sim.out, "old block hash does not match with the previous hash")
 		return false
 	}
 
 	if calculateBlockHash(newBlock) != newBlock.Hash {
-		fmt.Println("Recomputation of the hash is incorrect")
-		return false
-	}
-
-	return true
-}
-
-func isBlockValid(newBlock Block) bool {
-	oldBlock := proposer.Blockchain[len(proposer.Blockchain)-1]
-
-	if oldBlock.Index+1 != newBlock.Index {
-		fmt.Println("old block is not the previous block")
+		fmt.Fprintln(sim.out, "Recomputation of the hash is incorrect")
 		return false
 	}
 
-	if oldBlock.Hash != newBlock.PrevHash {
-		fmt.Println("old block hash does not match with the previous hash")
-		return false
-	}
-
-	if calculateBlockHash(newBlock) != newBlock.Hash {
-		fmt.Println("Recomputation of the hash is incorrect")
+	if !sim.isBlockSigned(newBlock) {
+		fmt.Fprintln(sim.out, "Block is not signed by its proposer")
 		return false
 	}
 
@@ -129,231 +122,226 @@ func isBlockValid(newBlock Block) bool {
 
 func isTransactionValid(transaction Transaction, validator *Validator) bool {
 	//Sender and receiver are both real users
-	if transaction.Sender == nil || transaction.Receiver == nil {
-		io.WriteString(validator.conn, "Transaction sender or receiver is not an active user\n")
+	if transaction.Sender == nil || transaction.Receiver == nil && len(transaction.Candidates) == 0 {
+		io.WriteString(validator.out, "Transaction sender or receiver is not an active user\n")
 		return false
 	}
+	for _, candidate := range transaction.Candidates {
+		if validator.sim.validatorByAddress(candidate) == nil {
+			io.WriteString(validator.out, "Vote candidate is not a validator\n")
+			return false
+		}
+	}
 
 	//Public key verifies transaction
 	signatureBytes, _ := hex.DecodeString(transaction.Signature)
 
 	// Compute the transaction hash
-	data := fmt.Sprintf("%d%p%p%f%f", transaction.ID, transaction.Sender, transaction.Receiver, transaction.Amount, transaction.Reward)
-	hash := sha256.Sum256([]byte(data))
+	hash := sha256.Sum256([]byte(transactionData(transaction)))
 
-	err := rsa.VerifyPKCS1v15(transaction.Sender.PublicKey, crypto.SHA256, hash[:], signatureBytes)
-	if err != nil {
-		io.WriteString(validator.conn, "Transaction could not be verified with public key\n")
+	if !ed25519.Verify(transaction.Sender.PublicKey, hash[:], signatureBytes) {
+		io.WriteString(validator.out, "Transaction could not be verified with public key\n")
 		return false
 	}
 
 	//Transaction was already spent
 	if validator.confirmedTransactions[transaction.ID] == true {
-		io.WriteString(validator.conn, "Transaction was already spent\n")
+		io.WriteString(validator.out, "Transaction was already spent\n")
 		return false
 	}
 	//User has insufficient funds
 	transaction.Sender.userLock.Lock()
-	if (transaction.Amount + transaction.Reward) > transaction.Sender.Balance {
-		io.WriteString(validator.conn, "Sender has insufficient funds\n")
+	//tokens locked behind a vote cannot be sent, but a new vote replaces the lock
+	available := transaction.Sender.Balance - transaction.Sender.locked
+	if len(transaction.Candidates) > 0 {
+		available = transaction.Sender.Balance
+	}
+	if (transaction.Amount + transaction.Reward) > available {
+		io.WriteString(validator.out, "Sender has insufficient funds\n")
 		transaction.Sender.userLock.Unlock()
 		return false
 	}
 	transaction.Sender.userLock.Unlock()
-	io.WriteString(validator.conn, "Transaction is valid\n")
+	io.WriteString(validator.out, "Transaction is valid\n")
 	return true
 }
 
-func handleValidatorConnection(conn net.Conn, runType string, malString string, splitView bool) {
-	defer conn.Close()
-
-	//Enter initial stake and whether or not validator is malicious
-	io.WriteString(conn, "Enter token stake:\n")
-	scannedBalance := bufio.NewScanner(conn)
-	if runType == "auto" {
-		randomStake := 0.0
-		rand.Seed(time.Now().UnixNano())
-		randomStake = rand.Float64()*700 + 300
-		randomStakeString := fmt.Sprintf("%f", randomStake)
-		scannedBalance = bufio.NewScanner(strings.NewReader(randomStakeString))
-	}
-	balance := 0.0
-	var err error
-	isMal := false
-	for scannedBalance.Scan() {
-		balance, err = strconv.ParseFloat(scannedBalance.Text(), 64)
-		if err != nil {
-			io.WriteString(conn, scannedBalance.Text()+" not a number")
-			return
-		}
-		break
-	}
-
-	io.WriteString(conn, "Is this node malicious (y/n)\n")
-	scannedMal := bufio.NewScanner(conn)
-	if runType == "auto" {
-		scannedMal = bufio.NewScanner(strings.NewReader(malString))
-	}
-	for scannedMal.Scan() {
-		if scannedMal.Text() != "y" && scannedMal.Text() != "n" {
-			io.WriteString(conn, scannedMal.Text()+" is not a valid response\n Please enter 'y' or 'n' ")
-			return
-		}
-		if scannedMal.Text() == "y" {
-			isMal = true
-		}
-		break
-	}
+// newValidator instantiates a validator that reports its activity to out and
+// registers it with the network
+func (sim *Simulation) newValidator(out io.Writer, stake float64, isMal bool) *Validator {
+	address := sim.randomAddress()
 
-	//Calculate address based on time
-	t := time.Now()
-	address := calculateHash(t.String())
+	//draw the key pair from the run's randomness so signatures are reproducible
+	//without the address giving the private key away
+	seed := make([]byte, ed25519.SeedSize)
+	sim.rng.Read(seed)
+	privateKey := ed25519.NewKeyFromSeed(seed)
 
 	//Instantiate new validator
 	unconfirmedTransactions := make(map[int]Transaction)
 	confirmedTransactions := make(map[int]bool)
 	curValidator := &Validator{
-		conn:                       conn,
-		incomingChannel:            make(chan interface{}),
-		outgoingChannel:            make(chan interface{}),
-		transactionChannel:         make(chan NewTransactionMessage),
-		delegateVoteRequestChannel: make(chan DelegateVoteRequestMessage),
-		delegateVoteChannel:        make(chan DelegateVoteMessage),
-		Address:                    address,
-		Stake:                      balance,
-		unconfirmedTransactions:    unconfirmedTransactions,
-		confirmedTransactions:      confirmedTransactions,
-		IsMalicious:                isMal,
-		validatorLock:              sync.Mutex{},
-		transactionPoolLock:        sync.Mutex{},
-		committeeCount:             0,
-		proposerCount:              0,
-		reputation:                 5.0,
-	}
-
-	//set view of chain to fork if needed for balance attack
-	if splitView{
-		curValidator.Blockchain = make([]Block, len(balanceAttackFork))
-		copy(curValidator.Blockchain, balanceAttackFork)
-	}else{
-		curValidator.Blockchain = make([]Block, len(CertifiedBlockchain))
-		copy(curValidator.Blockchain, CertifiedBlockchain)
+		sim:                     sim,
+		out:                     out,
+		Address:                 address,
+		PublicKey:               privateKey.Public().(ed25519.PublicKey),
+		privateKey:              privateKey,
+		Stake:                   stake,
+		initialStake:            stake,
+		unconfirmedTransactions: unconfirmedTransactions,
+		confirmedTransactions:   confirmedTransactions,
+		IsMalicious:             isMal,
+		validatorLock:           sync.Mutex{},
+		transactionPoolLock:     sync.Mutex{},
+		committeeCount:          0,
+		proposerCount:           0,
+		reputation:              5.0,
 	}
 
-	validators = append(validators, curValidator)
+	curValidator.Blockchain = make([]Block, len(sim.CertifiedBlockchain))
+	copy(curValidator.Blockchain, sim.CertifiedBlockchain)
+	sim.attack.OnValidatorJoin(sim, curValidator)
 
-	ForkedBlockchain[forkedCounter%2][forkedCounter/2] = curValidator
-	forkedCounter += 1
+	sim.validatorsSliceLock.Lock()
+	sim.validators = append(sim.validators, curValidator)
+	sim.validatorsSliceLock.Unlock()
 
 	if isMal {
-		malValidators = append(malValidators, curValidator)
+		sim.malValidators = append(sim.malValidators, curValidator)
 	}
 
-	fmt.Printf("new validator count: %d\n", len(validators))
+	fmt.Fprintf(sim.out, "new validator count: %d\n", len(sim.validators))
+	return curValidator
+}
 
-	//listen for transactions in transaction channel
-	go func() {
-		for {
-			msg := <-curValidator.transactionChannel
-			//Receiving unverified transactions
-			io.WriteString(conn, "Received unverified transaction\n")
-			isValid := isTransactionValid(msg.transaction, curValidator)
-			curValidator.transactionPoolLock.Lock()
-			if isValid {
-				curValidator.unconfirmedTransactions[msg.transaction.ID] = msg.transaction
-			}
-			curValidator.transactionPoolLock.Unlock()
+// bootstrap has a validator joining the network pick its chain among the
+// chains its peers offer whose blocks are all signed by their proposers: the
+// one whose blocks since the chains part were signed by the most stake, then
+// the longest, keeping the first of equally heavy and long ones. Under
+// casper_ffg the last finalized checkpoint acts as a weak subjectivity
+// checkpoint, and chains without it are refused. It reports which chain was
+// picked, or -1 if none holds.
+func (curValidator *Validator) bootstrap(chains [][]Block) int {
+	sim := curValidator.sim
+	var checkpoint string
+	if finalized := sim.FinalizedBlockchain(); len(finalized) > 0 {
+		checkpoint = finalized[len(finalized)-1].Hash
+	}
+	fork := len(chains[0])
+	for _, chain := range chains[1:] {
+		if common := len(chain) - reorgDepth(chain, chains[0]); common < fork {
+			fork = common
 		}
-	}()
-
-	//listen for delegate vote requests in delegate vote channel
-	go func() {
-		for {
-			msg := <-curValidator.delegateVoteRequestChannel
-			io.WriteString(conn, "Received delegate vote requests\n")
-			validatorsCopy := make([]*Validator, len(validators))
-			copy(validatorsCopy, validators)
-
-			sort.Slice(validatorsCopy, func(i, j int) bool {
-				return validatorsCopy[i].reputation > validatorsCopy[j].reputation
-			})
-			voteMsg := DelegateVoteMessage{
-				delegateVotes: validatorsCopy[:msg.delegateSize],
-			}
-
-			curValidator.delegateVoteChannel <- voteMsg
+	}
+	picked := -1
+	heaviest := 0.0
+	for i, chain := range chains {
+		valid := checkpoint == "" || hasBlock(chain, checkpoint)
+		for _, block := range chain {
+			valid = valid && sim.isBlockSigned(block)
+		}
+		if !valid {
+			fmt.Fprintf(sim.out, "Validator %s refuses chain %d\n", curValidator.Address[:3], i)
+			continue
+		}
+		weight := sim.signingStake(chain[fork:])
+		if picked < 0 || weight > heaviest || weight == heaviest && len(chain) > len(chains[picked]) {
+			picked = i
+			heaviest = weight
+		}
+	}
+	if picked >= 0 {
+		curValidator.Blockchain = make([]Block, len(chains[picked]))
+		copy(curValidator.Blockchain, chains[picked])
+	}
+	return picked
+}
 
+// signingStake adds up the stake the proposers of blocks hold now, counting
+// every proposer once, so signing many blocks with few keys or with keys of
+// validators that left adds little
+func (sim *Simulation) signingStake(blocks []Block) float64 {
+	signers := make(map[string]bool)
+	stake := 0.0
+	for _, block := range blocks {
+		if signers[block.Validator] {
+			continue
 		}
-	}()
-
-	//listen for messages in communication channel
-	for {
-		msg := <-curValidator.incomingChannel
-		switch msg := msg.(type) {
-		//Receiving block to validate
-		case ValidateBlockMessage:
-			io.WriteString(conn, "Received a Block to validate\n")
-			isValid := isBlockValid(msg.newBlock)
-			if currAttack == "balance"{
-				isValid = balanceAttackIsBlockValid(msg.newBlock, msg.malVote, curValidator.IsMalicious)
-			} 
-			validationStatusMessage := ValidationStatusMessage{
-				isValid: isValid,
-			}
-			curValidator.outgoingChannel <- validationStatusMessage
-		//Receiving blocks to validate (short attack ed.)
-		case ValidateShortAttackBlockMessage:
-			io.WriteString(conn, "Received both Blocks to validate\n")
-			isValid := isBlockValid(msg.newBlock)
-			isValidTwo := isBlockValid(msg.newBlockTwo)
-			validationShortAttackStatusMessage := ValidationShortAttackStatusMessage{
-				isValid:    isValid,
-				isValidTwo: isValidTwo,
-			}
-			curValidator.outgoingChannel <- validationShortAttackStatusMessage
-		//Receiving verified transactions
-		case VerifiedBlockMessage:
-			io.WriteString(conn, "Received verified transaction\n")
-			curValidatorLastBlock := curValidator.Blockchain[len(curValidator.Blockchain)-1]
-			if msg.newBlock.PrevHash != curValidatorLastBlock.Hash || msg.newBlock.Index != curValidatorLastBlock.Index + 1 {
-				io.WriteString(conn, "Validator rejected verified block because of different view of chain\n")
-			} else{
-				//put verified transactions into confirmed slice for validator
-				curValidator.transactionPoolLock.Lock()
-				for _, transaction := range msg.transactions {
-					curValidator.confirmedTransactions[transaction.ID] = true
-				}
-
-				//take transactions out of unconfirmed map
-				for _, transaction := range msg.transactions {
-					delete(curValidator.unconfirmedTransactions, transaction.ID)
-				}
-				curValidator.transactionPoolLock.Unlock()
-
-				//add new block
-				curValidator.Blockchain = append(curValidator.Blockchain, msg.newBlock)
-			}
+		signers[block.Validator] = true
+		if validator := sim.validatorByAddress(block.Validator); validator != nil {
+			stake += validator.Stake
+		}
+	}
+	return stake
+}
 
-		case VerifiedShortAttackBlockMessage:
-			io.WriteString(conn, "Received verified transaction\n")
-			//put verified transactions into confirmed slice for validator
-			curValidator.validatorLock.Lock()
-			for _, transaction := range msg.transactions {
-				curValidator.confirmedTransactions[transaction.ID] = true
-			}
+// receiveTransaction adds a broadcast transaction to the local mempool if it is valid
+func (curValidator *Validator) receiveTransaction(msg NewTransactionMessage) {
+	//Receiving unverified transactions
+	io.WriteString(curValidator.out, "Received unverified transaction\n")
+	isValid := isTransactionValid(msg.transaction, curValidator)
+	curValidator.transactionPoolLock.Lock()
+	if isValid {
+		curValidator.unconfirmedTransactions[msg.transaction.ID] = msg.transaction
+	}
+	curValidator.transactionPoolLock.Unlock()
+}
 
-			//take transactions out of unconfirmed map
-			for _, transaction := range msg.transactions {
-				delete(curValidator.unconfirmedTransactions, transaction.ID)
-			}
-			curValidator.validatorLock.Unlock()
+// receiveDelegateVoteRequest votes for the validators with the highest reputation
+func (curValidator *Validator) receiveDelegateVoteRequest(msg DelegateVoteRequestMessage) DelegateVoteMessage {
+	io.WriteString(curValidator.out, "Received delegate vote requests\n")
+	validatorsCopy := make([]*Validator, len(curValidator.sim.validators))
+	copy(validatorsCopy, curValidator.sim.validators)
+
+	sort.Slice(validatorsCopy, func(i, j int) bool {
+		return validatorsCopy[i].reputation > validatorsCopy[j].reputation
+	})
+	//fewer validators than delegates may have joined
+	if msg.delegateSize < len(validatorsCopy) {
+		validatorsCopy = validatorsCopy[:msg.delegateSize]
+	}
+	return DelegateVoteMessage{
+		delegateVotes: validatorsCopy,
+	}
+}
 
-			//add new block
-			curValidator.Blockchain = append(curValidator.Blockchain, msg.newBlock)
-		case VerifiedShortAttackBlockTwoMessage:
-			io.WriteString(conn, "Received verified transaction\n")
+// receive handles a message from the global server and returns the reply, if
+// the message expects one
+func (curValidator *Validator) receive(msg interface{}) interface{} {
+	out := curValidator.out
+	switch msg := msg.(type) {
+	//Receiving block to validate
+	case ValidateBlockMessage:
+		io.WriteString(out, "Received a Block to validate\n")
+		isValid := curValidator.sim.isBlockValid(msg.newBlock)
+		isValid = curValidator.sim.attack.OnVote(curValidator.sim, curValidator, msg.newBlock, isValid)
+		return ValidationStatusMessage{
+			isValid: isValid,
+		}
+	//Receiving blocks to validate (short attack ed.)
+	case ValidateShortAttackBlockMessage:
+		io.WriteString(out, "Received both Blocks to validate\n")
+		isValid := curValidator.sim.isBlockValid(msg.newBlock)
+		isValidTwo := curValidator.sim.isBlockValid(msg.newBlockTwo)
+		//voting for both blocks is slashable, so only the first gets the vote
+		if curValidator.sim.scenario.SlashEquivocation && isValid {
+			isValidTwo = false
+		}
+		isValid = curValidator.sim.attack.OnVote(curValidator.sim, curValidator, msg.newBlock, isValid)
+		isValidTwo = curValidator.sim.attack.OnVote(curValidator.sim, curValidator, msg.newBlockTwo, isValidTwo)
+		return ValidationShortAttackStatusMessage{
+			isValid:    isValid,
+			isValidTwo: isValidTwo,
+		}
+	//Receiving verified transactions
+	case VerifiedBlockMessage:
+		io.WriteString(out, "Received verified transaction\n")
+		curValidatorLastBlock := curValidator.Blockchain[len(curValidator.Blockchain)-1]
+		if msg.newBlock.PrevHash != curValidatorLastBlock.Hash || msg.newBlock.Index != curValidatorLastBlock.Index+1 {
+			io.WriteString(out, "Validator rejected verified block because of different view of chain\n")
+		} else {
 			//put verified transactions into confirmed slice for validator
-			curValidator.validatorLock.Lock()
+			curValidator.transactionPoolLock.Lock()
 			for _, transaction := range msg.transactions {
 				curValidator.confirmedTransactions[transaction.ID] = true
 			}
@@ -362,14 +350,30 @@ func handleValidatorConnection(conn net.Conn, runType string, malString string,
 			for _, transaction := range msg.transactions {
 				delete(curValidator.unconfirmedTransactions, transaction.ID)
 			}
-			curValidator.validatorLock.Unlock()
+			curValidator.transactionPoolLock.Unlock()
 
 			//add new block
-			curValidator.Blockchain = append(curValidator.Blockchain, msg.newBlockTwo)
+			curValidator.Blockchain = append(curValidator.Blockchain, msg.newBlock)
+		}
 
-		default:
-			io.WriteString(conn, "Received an unknown struct: %+v\n")
+	case VerifiedShortAttackBlockMessage:
+		io.WriteString(out, "Received verified transaction\n")
+		//put verified transactions into confirmed slice for validator
+		curValidator.validatorLock.Lock()
+		for _, transaction := range msg.transactions {
+			curValidator.confirmedTransactions[transaction.ID] = true
 		}
-	}
 
+		//take transactions out of unconfirmed map
+		for _, transaction := range msg.transactions {
+			delete(curValidator.unconfirmedTransactions, transaction.ID)
+		}
+		curValidator.validatorLock.Unlock()
+
+		//add new block
+		curValidator.Blockchain = append(curValidator.Blockchain, msg.newBlock)
+	default:
+		io.WriteString(out, fmt.Sprintf("Received an unknown struct: %+v\n", msg))
+	}
+	return nil
 }
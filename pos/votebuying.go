@@ -0,0 +1,47 @@
+package pos
+
+import (
+	"fmt"
+	"sort"
+)
+
+// voteBuying has the malicious validators act as a cartel buying dpos votes
+// and npos nominations. Whenever a user is about to vote, the cartel members
+// with the fewest tokens behind them, as many as the user picked, offer the
+// user bribe times the tokens it locks, paid out of the stake of the member
+// with the fewest, and the user takes it and votes for the members instead.
+// Spreading the bought votes over the members wins the cartel as many
+// delegate seats as its budget allows.
+type voteBuying struct {
+	baseAttack
+}
+
+func (v *voteBuying) OnDelegateVote(sim *Simulation, user *User, candidates []*Validator, amount float64) []*Validator {
+	honest := false
+	for _, candidate := range candidates {
+		honest = honest || !candidate.IsMalicious
+	}
+	if !honest || len(sim.malValidators) == 0 {
+		return candidates
+	}
+
+	votes := sim.delegateVotes()
+	members := make([]*Validator, len(sim.malValidators))
+	copy(members, sim.malValidators)
+	sort.SliceStable(members, func(i, j int) bool {
+		return votes[members[i].Address] < votes[members[j].Address]
+	})
+	if len(members) > len(candidates) {
+		members = members[:len(candidates)]
+	}
+	bribe := sim.scenario.Bribe * amount
+	if bribe > members[0].Stake {
+		return candidates
+	}
+	members[0].Stake -= bribe
+	user.Balance += bribe
+	sim.security.VoteBuying.VotesBought++
+	sim.security.VoteBuying.BribesPaid += bribe
+	fmt.Fprintf(sim.out, "Validator %s pays %s %.2f to vote for it\n", members[0].Address[:3], user.Name, bribe)
+	return members
+}